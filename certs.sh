@@ -0,0 +1,37 @@
+#!/bin/bash
+
+# Generates a throwaway CA, a broker certificate for localhost and a client
+# certificate for the "peril" user, for trying out TLS locally. The client
+# certificate's common name is the user name RabbitMQ sees under EXTERNAL auth.
+
+set -e
+
+dir=${1:-certs}
+client=${2:-peril}
+days=365
+
+mkdir -p "$dir"
+cd "$dir"
+
+echo "Generating CA..."
+openssl req -x509 -newkey rsa:2048 -nodes -days "$days" \
+  -subj "/CN=Peril local CA" -keyout ca.key -out ca.pem
+
+echo "Generating broker certificate..."
+openssl req -newkey rsa:2048 -nodes -subj "/CN=localhost" \
+  -keyout server.key -out server.csr
+openssl x509 -req -in server.csr -CA ca.pem -CAkey ca.key -CAcreateserial -days "$days" \
+  -extfile <(printf "subjectAltName=DNS:localhost,IP:127.0.0.1\nextendedKeyUsage=serverAuth") \
+  -out server.pem
+
+echo "Generating client certificate for $client..."
+openssl req -newkey rsa:2048 -nodes -subj "/CN=$client" \
+  -keyout client.key -out client.csr
+openssl x509 -req -in client.csr -CA ca.pem -CAkey ca.key -CAcreateserial -days "$days" \
+  -extfile <(printf "extendedKeyUsage=clientAuth") \
+  -out client.pem
+
+rm -f server.csr client.csr ca.srl
+# the broker container reads its key as a different user
+chmod 644 server.key
+echo "Certificates written to $dir"
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/gamelogic"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <username>.events.jsonl\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	session := flag.Int("session", 0, "session in the file to replay, counting from 1; 0 is the last")
+	run := flag.Bool("run", false, "print every event and the final state instead of stepping")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	events, err := gamelogic.ReadEvents(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	sessions := splitSessions(events)
+	if len(sessions) == 0 {
+		fatal(fmt.Errorf("%s has no recorded sessions", flag.Arg(0)))
+	}
+	if *session < 0 || *session > len(sessions) {
+		fatal(fmt.Errorf("there are %v sessions, not %v", len(sessions), *session))
+	}
+	if *session == 0 {
+		*session = len(sessions)
+	}
+	events = sessions[*session-1]
+	fmt.Printf("Replaying session %v of %v: %v events.\n", *session, len(sessions), len(events))
+
+	if *run {
+		for _, e := range events {
+			fmt.Println(e)
+		}
+		gs, err := gamelogic.Replay(events)
+		if err != nil {
+			fatal(err)
+		}
+		gs.CommandStatus()
+		return
+	}
+
+	r := &replayer{events: events}
+	r.seek(1)
+	printHelp()
+	for {
+		words := gamelogic.GetInput()
+		if words == nil {
+			return
+		}
+		if len(words) == 0 {
+			words = []string{"next"}
+		}
+		switch words[0] {
+		case "next", "n":
+			n := 1
+			if len(words) > 1 {
+				n, err = strconv.Atoi(words[1])
+				if err != nil {
+					fmt.Println("next takes a number of events")
+					continue
+				}
+			}
+			r.step(n)
+		case "back", "b":
+			r.seek(r.at - 1)
+		case "goto":
+			if len(words) < 2 {
+				fmt.Println("goto takes an event number")
+				continue
+			}
+			seq, err := strconv.Atoi(words[1])
+			if err != nil {
+				fmt.Println("goto takes an event number")
+				continue
+			}
+			r.seek(r.indexOf(seq))
+		case "end":
+			r.step(len(r.events))
+		case "status":
+			r.state.CommandStatus()
+		case "events":
+			for i, e := range r.events {
+				marker := "  "
+				if i+1 == r.at {
+					marker = "> "
+				}
+				fmt.Println(marker + e.String())
+			}
+		case "quit", "q":
+			return
+		default:
+			printHelp()
+		}
+	}
+}
+
+// replayer holds a game state rebuilt from the first at events.
+type replayer struct {
+	events []gamelogic.Event
+	at     int
+	state  *gamelogic.GameState
+}
+
+// step applies the next n events.
+func (r *replayer) step(n int) {
+	if r.at == len(r.events) {
+		fmt.Println("At the end of the session.")
+		return
+	}
+	for ; n > 0 && r.at < len(r.events); n-- {
+		e := r.events[r.at]
+		r.state.Apply(e)
+		r.at++
+		fmt.Println(e)
+	}
+}
+
+// seek rebuilds the state from the start of the session up to the at'th
+// event, which comes out the same every time as replays are deterministic.
+func (r *replayer) seek(at int) {
+	at = min(max(at, 1), len(r.events))
+	gs, err := gamelogic.Replay(r.events[:at])
+	if err != nil {
+		fatal(err)
+	}
+	r.state, r.at = gs, at
+	fmt.Println(r.events[at-1])
+}
+
+// indexOf returns how many events there are up to and including the one
+// numbered seq.
+func (r *replayer) indexOf(seq int) int {
+	for i, e := range r.events {
+		if e.Seq >= seq {
+			return i + 1
+		}
+	}
+	return len(r.events)
+}
+
+// splitSessions splits a history file into the sessions appended to it, each
+// beginning with a started event. Anything before the first is dropped.
+func splitSessions(events []gamelogic.Event) [][]gamelogic.Event {
+	sessions := [][]gamelogic.Event{}
+	for _, e := range events {
+		if e.Type == gamelogic.EventStarted {
+			sessions = append(sessions, []gamelogic.Event{})
+		}
+		if len(sessions) > 0 {
+			sessions[len(sessions)-1] = append(sessions[len(sessions)-1], e)
+		}
+	}
+	return sessions
+}
+
+func printHelp() {
+	fmt.Println("Possible commands:")
+	fmt.Println("* next [n] (or just enter)")
+	fmt.Println("* back")
+	fmt.Println("* goto <event number>")
+	fmt.Println("* end")
+	fmt.Println("* status")
+	fmt.Println("* events")
+	fmt.Println("* quit")
+	fmt.Println("* help")
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/config"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/pubsub"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/routing"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/topology"
+)
+
+// TestSpawnMoveWar plays a war through a MemoryBroker: two players spawn
+// units, one moves onto the other, and the server's verdict reaches both.
+// The loser has nothing left, so the game is over too.
+func TestSpawnMoveWar(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	t.Cleanup(func() {
+		paused.Store(false)
+		gameOver.Store(false)
+	})
+
+	b := pubsub.NewMemoryBroker()
+	conn := b.Dial()
+	defer conn.Close()
+	ch, err := conn.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	if err := topology.Peril(cfg).Apply(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	// the server, subscribed as main does
+	scenario, err := gamelogic.LoadScenario("skirmish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := gamelogic.NewWorld(scenario)
+	publisher, err := pubsub.NewConfirmingPublisher(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	spawns, err := pubsub.SubscribeWithMetadata(ctx, conn, cfg.Exchanges.Topic, cfg.Queues.Spawns, routing.SpawnsPrefix+".*", cfg.Queues.SharedQueueType(), handlerSpawn(world, publisher, cfg.Exchanges, false),
+		pubsub.WithDefaultCodec(pubsub.JSON),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spawns.Close()
+	moves, err := pubsub.SubscribeWithMetadata(ctx, conn, cfg.Exchanges.Topic, cfg.Queues.Moves, routing.ArmyMovesPrefix+".*", cfg.Queues.SharedQueueType(), handlerMove(world, publisher, cfg.Exchanges, false),
+		pubsub.WithDefaultCodec(pubsub.JSON),
+		pubsub.WithRetry(unknownUnitRetryPolicy),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer moves.Close()
+
+	// the players, each hearing about wars on a queue of their own
+	players := map[string]*gamelogic.GameState{}
+	outcomes := map[string]chan gamelogic.WarOutcome{}
+	for _, username := range []string{"alice", "bob"} {
+		gs := gamelogic.NewGameState(username)
+		if err := gs.JoinGame(gamelogic.GameInfo{ID: "test", Scenario: scenario.Data()}); err != nil {
+			t.Fatal(err)
+		}
+		outcome := make(chan gamelogic.WarOutcome, 1)
+		sub, err := pubsub.SubscribeJSON(ctx, conn, cfg.Exchanges.Topic, routing.WarResultsPrefix+"."+username, routing.WarResultsPrefix+".*", pubsub.TransientQueue,
+			func(wr gamelogic.WarResult) pubsub.Acktype {
+				outcome <- gs.HandleWarResult(wr)
+				return pubsub.Ack
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sub.Close()
+		players[username] = gs
+		outcomes[username] = outcome
+	}
+
+	over := make(chan gamelogic.GameOver, 1)
+	overSub, err := pubsub.SubscribeJSON(ctx, conn, cfg.Exchanges.Direct, routing.GameOverKey+".alice", routing.GameOverKey, pubsub.TransientQueue,
+		func(g gamelogic.GameOver) pubsub.Acktype {
+			over <- g
+			return pubsub.Ack
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer overSub.Close()
+
+	spawn := func(username string, words ...string) gamelogic.Unit {
+		t.Helper()
+		gs := players[username]
+		unit, err := gs.CommandSpawn(append([]string{"spawn"}, words...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = pubsub.PublishJSON(ctx, ch, cfg.Exchanges.Topic, routing.SpawnsPrefix+"."+username, gamelogic.SpawnedUnit{Username: username, Unit: unit}, pubsub.WithSender(username))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for len(world.Units(username, []int{unit.ID})) == 0 {
+			if ctx.Err() != nil {
+				t.Fatalf("the server never spawned %s's unit %v", username, unit.ID)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return unit
+	}
+	defender := spawn("alice", "north", "infantry")
+	attacker := spawn("bob", "east", "artillery")
+
+	move, err := players["bob"].CommandMove([]string{"move", "north", strconv.Itoa(attacker.ID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = pubsub.PublishJSON(ctx, ch, cfg.Exchanges.Topic, routing.ArmyMovesPrefix+".bob", move, pubsub.WithSender("bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]gamelogic.WarOutcome{"alice": gamelogic.WarOutcomeOpponentWon, "bob": gamelogic.WarOutcomeYouWon}
+	for username, outcome := range want {
+		select {
+		case got := <-outcomes[username]:
+			if got != outcome {
+				t.Errorf("%s got outcome %v, want %v", username, got, outcome)
+			}
+		case <-ctx.Done():
+			t.Fatalf("%s never heard about the war", username)
+		}
+	}
+
+	if _, ok := players["alice"].GetUnit(defender.ID); ok {
+		t.Error("alice still has the unit that lost")
+	}
+	if units := world.Units("alice", []int{defender.ID}); len(units) != 0 {
+		t.Errorf("the server still has alice's units %v", units)
+	}
+	if units := world.Units("bob", []int{attacker.ID}); len(units) != 1 || units[0].Location != "north" {
+		t.Errorf("the server has bob's units at %v, want the artillery in north", units)
+	}
+	select {
+	case g := <-over:
+		if g.Winner != "bob" {
+			t.Errorf("%s won the game, want bob", g.Winner)
+		}
+	case <-ctx.Done():
+		t.Fatal("the game never ended")
+	}
+
+	// closing waits for the server to finish handling the move
+	moves.Close()
+	if n := b.QueueLength(cfg.Queues.GameLogs); n == 0 {
+		t.Error("the war was not logged")
+	}
+	if !paused.Load() {
+		t.Error("the game was not paused once it was over")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/config"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/pubsub"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// recorder is a Publisher that keeps what it is given.
+type recorder struct {
+	keys []string
+	msgs []amqp.Publishing
+}
+
+func (r *recorder) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	r.keys = append(r.keys, key)
+	r.msgs = append(r.msgs, msg)
+	return nil
+}
+
+func TestCheckSender(t *testing.T) {
+	tests := []struct {
+		name     string
+		meta     pubsub.Metadata
+		verified bool
+		ok       bool
+	}{
+		{"no sender", pubsub.Metadata{}, false, false},
+		{"claimed by the player", pubsub.Metadata{Sender: "alice"}, false, true},
+		{"claimed by someone else", pubsub.Metadata{Sender: "mallory"}, false, false},
+		{"verified someone else", pubsub.Metadata{Sender: "mallory", SenderVerified: true}, true, false},
+		{"unverified when required", pubsub.Metadata{Sender: "alice"}, true, false},
+		{"verified when required", pubsub.Metadata{Sender: "alice", SenderVerified: true}, true, true},
+	}
+	for _, tt := range tests {
+		err := checkSender(tt.meta, "alice", tt.verified)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestHandlerLogsTrustsOnlyVerifiedSenders(t *testing.T) {
+	tests := []struct {
+		name string
+		meta pubsub.Metadata
+		want string
+	}{
+		{"no sender", pubsub.Metadata{}, "alice"},
+		{"unverified sender", pubsub.Metadata{Sender: "mallory"}, "alice"},
+		{"verified sender", pubsub.Metadata{Sender: "bob", SenderVerified: true}, "bob"},
+	}
+	for _, tt := range tests {
+		var written []routing.GameLog
+		handler := handlerLogs(func(gl routing.GameLog) error {
+			written = append(written, gl)
+			return nil
+		})
+		if got := handler(tt.meta, routing.GameLog{Username: "alice", Message: "hello"}); got != pubsub.Ack {
+			t.Errorf("%s: got %v, want Ack", tt.name, got)
+		}
+		if len(written) != 1 || written[0].Username != tt.want {
+			t.Errorf("%s: wrote %+v, want it logged as %s", tt.name, written, tt.want)
+		}
+	}
+}
+
+func TestHandlerMoveRejectsUnknownUnitsOnceRetriesRunOut(t *testing.T) {
+	scenario, err := gamelogic.LoadScenario("skirmish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := gamelogic.NewWorld(scenario)
+	err = world.Spawn(gamelogic.SpawnedUnit{Username: "alice", Unit: gamelogic.Unit{ID: 1, Rank: gamelogic.RankInfantry, Location: "north"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &recorder{}
+	handler := handlerMove(world, pub, config.Exchanges{Topic: "peril_topic"}, false)
+
+	move := gamelogic.ArmyMove{
+		Player:     gamelogic.Player{Username: "alice"},
+		Units:      []gamelogic.Unit{{ID: 1, Rank: gamelogic.RankInfantry, Location: "north"}, {ID: 2, Rank: gamelogic.RankInfantry, Location: "north"}},
+		ToLocation: "east",
+		Path:       []gamelogic.Location{"north", "east"},
+	}
+	last := unknownUnitRetryPolicy.MaxAttempts
+	for retries := 0; retries < last; retries++ {
+		got := handler(pubsub.Metadata{Sender: "alice", Retries: retries}, move)
+		if got != pubsub.NackRetryLater {
+			t.Fatalf("after %d retries: got %v, want NackRetryLater", retries, got)
+		}
+	}
+	if len(pub.msgs) != 0 {
+		t.Fatalf("published %d messages while retrying", len(pub.msgs))
+	}
+
+	got := handler(pubsub.Metadata{Sender: "alice", Retries: last}, move)
+	if got != pubsub.NackDiscard {
+		t.Fatalf("after %d retries: got %v, want NackDiscard", last, got)
+	}
+	if len(pub.msgs) != 1 || pub.keys[0] != routing.MoveRejectionsPrefix+".alice" {
+		t.Fatalf("published to %v, want one move rejection", pub.keys)
+	}
+	var rejection gamelogic.MoveRejection
+	if err := json.Unmarshal(pub.msgs[0].Body, &rejection); err != nil {
+		t.Fatal(err)
+	}
+	if len(rejection.Units) != 1 || rejection.Units[0].ID != 1 || rejection.Units[0].Location != "north" {
+		t.Errorf("rejection lists units %v, want unit 1 still in north", rejection.Units)
+	}
+}
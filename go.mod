@@ -2,4 +2,8 @@ module github.com/ChernakovEgor/learn-pub-sub-starter
 
 go 1.22.6
 
-require github.com/rabbitmq/amqp091-go v1.10.0
+require (
+	github.com/rabbitmq/amqp091-go v1.10.0
+	google.golang.org/protobuf v1.36.6
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,265 @@
+package gamelogic
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+type EventType string
+
+const (
+	// EventStarted begins a history with the whole state of the game at the
+	// time, so a history can start mid-game.
+	EventStarted      EventType = "started"
+	EventGameJoined   EventType = "game_joined"
+	EventRestored     EventType = "restored"
+	EventUnitSpawned  EventType = "unit_spawned"
+	EventUnitsMoved   EventType = "units_moved"
+	EventUnitsRemoved EventType = "units_removed"
+	// EventUnitIDsSkipped moves NextUnitID past IDs the server has already
+	// seen from the player.
+	EventUnitIDsSkipped EventType = "unit_ids_skipped"
+	EventPaused         EventType = "paused"
+	EventResumed        EventType = "resumed"
+)
+
+// Event is a single change to a GameState. Every change goes through one, so
+// folding a game's events over an empty state rebuilds it exactly.
+type Event struct {
+	Seq  int       `json:"seq"`
+	Time time.Time `json:"time"`
+	Type EventType `json:"type"`
+	// Game is set for started and game_joined.
+	Game *GameInfo `json:"game,omitempty"`
+	// Snapshot is set for started and restored.
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+	// Units is set for unit_spawned, units_moved and units_removed.
+	Units []Unit `json:"units,omitempty"`
+	// NextUnitID is set for unit_ids_skipped.
+	NextUnitID int `json:"next_unit_id,omitempty"`
+}
+
+func (e Event) String() string {
+	switch e.Type {
+	case EventStarted:
+		return fmt.Sprintf("#%v started game %q as %s with %v units", e.Seq, e.Game.ID, e.Snapshot.Player.Username, len(e.Snapshot.Player.Units))
+	case EventGameJoined:
+		return fmt.Sprintf("#%v joined game %q, scenario %s", e.Seq, e.Game.ID, e.Game.Scenario.Name)
+	case EventRestored:
+		return fmt.Sprintf("#%v restored a snapshot with %v units", e.Seq, len(e.Snapshot.Player.Units))
+	case EventUnitSpawned, EventUnitsMoved, EventUnitsRemoved:
+		s := fmt.Sprintf("#%v %s:", e.Seq, e.Type)
+		for _, unit := range e.Units {
+			s += fmt.Sprintf(" %v (%s in %s)", unit.ID, unit.Rank, unit.Location)
+		}
+		return s
+	case EventUnitIDsSkipped:
+		return fmt.Sprintf("#%v %s: next id %v", e.Seq, e.Type, e.NextUnitID)
+	default:
+		return fmt.Sprintf("#%v %s", e.Seq, e.Type)
+	}
+}
+
+// EventLog is where a GameState records its events.
+type EventLog interface {
+	Append(e Event) error
+}
+
+// FileEventLog appends events to a file, one JSON object per line.
+type FileEventLog struct {
+	f *os.File
+}
+
+func OpenEventLog(path string) (*FileEventLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open event log: %v", err)
+	}
+	return &FileEventLog{f: f}, nil
+}
+
+func (l *FileEventLog) Append(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = l.f.Write(append(b, '\n'))
+	return err
+}
+
+func (l *FileEventLog) Close() error {
+	return l.f.Close()
+}
+
+// ReadEvents reads the events of every history recorded in an event log.
+func ReadEvents(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events := []Event{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if err := e.check(); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	return events, nil
+}
+
+func (e Event) check() error {
+	switch e.Type {
+	case EventStarted:
+		if e.Game == nil || e.Snapshot == nil {
+			return fmt.Errorf("%s event without game or snapshot", e.Type)
+		}
+	case EventGameJoined:
+		if e.Game == nil {
+			return fmt.Errorf("%s event without game", e.Type)
+		}
+	case EventRestored:
+		if e.Snapshot == nil {
+			return fmt.Errorf("%s event without snapshot", e.Type)
+		}
+	case EventUnitSpawned, EventUnitsMoved, EventUnitsRemoved, EventUnitIDsSkipped, EventPaused, EventResumed:
+	default:
+		return fmt.Errorf("unknown event %q", e.Type)
+	}
+	return nil
+}
+
+// Replay folds events into a new game state. The events have to begin with a
+// started event, which says whose game it is.
+func Replay(events []Event) (*GameState, error) {
+	if len(events) == 0 || events[0].Type != EventStarted {
+		return nil, fmt.Errorf("history does not begin with a %s event", EventStarted)
+	}
+	gs := NewGameState(events[0].Snapshot.Player.Username)
+	for _, e := range events {
+		gs.Apply(e)
+	}
+	return gs, nil
+}
+
+// RecordTo starts recording every change to the game in log, beginning with
+// the current state.
+func (gs *GameState) RecordTo(log EventLog) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.events = log
+	snapshot := gs.snapshotLocked()
+	gs.recordLocked(Event{
+		Type:     EventStarted,
+		Game:     &GameInfo{ID: gs.GameID, Scenario: gs.Scenario.Data()},
+		Snapshot: &snapshot,
+	})
+}
+
+// Apply changes the game state by an event without recording it.
+func (gs *GameState) Apply(e Event) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.seq = max(gs.seq, e.Seq)
+	gs.apply(e)
+}
+
+func (gs *GameState) record(e Event) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.recordLocked(e)
+}
+
+// recordLocked applies e and appends it to the event log. gs.mu must be held,
+// which also keeps the log in the order the events were applied.
+func (gs *GameState) recordLocked(e Event) {
+	gs.seq++
+	e.Seq = gs.seq
+	e.Time = time.Now().UTC()
+	gs.apply(e)
+	if gs.events == nil {
+		return
+	}
+	err := gs.events.Append(e)
+	if err != nil {
+		slog.Warn("could not record event", "seq", e.Seq, "type", e.Type, "err", err)
+	}
+}
+
+// apply is the only place the game state changes. It must not depend on
+// anything but the state and the event, so that replays come out the same.
+func (gs *GameState) apply(e Event) {
+	switch e.Type {
+	case EventStarted:
+		gs.join(*e.Game)
+		gs.restore(*e.Snapshot)
+		gs.NextUnitID = e.Snapshot.NextUnitID
+	case EventGameJoined:
+		gs.join(*e.Game)
+	case EventRestored:
+		gs.restore(*e.Snapshot)
+	case EventUnitSpawned:
+		for _, unit := range e.Units {
+			gs.Player.Units[unit.ID] = unit
+			gs.NextUnitID = max(gs.NextUnitID, unit.ID+1)
+		}
+	case EventUnitsMoved:
+		for _, unit := range e.Units {
+			gs.Player.Units[unit.ID] = unit
+		}
+	case EventUnitsRemoved:
+		for _, unit := range e.Units {
+			delete(gs.Player.Units, unit.ID)
+		}
+	case EventUnitIDsSkipped:
+		gs.NextUnitID = max(gs.NextUnitID, e.NextUnitID)
+	case EventPaused:
+		gs.Paused = true
+	case EventResumed:
+		gs.Paused = false
+	}
+}
+
+func (gs *GameState) join(info GameInfo) {
+	gs.GameID = info.ID
+	scenario, err := NewScenario(info.Scenario)
+	if err != nil {
+		slog.Warn("event has an invalid scenario, keeping the current one", "err", err)
+		return
+	}
+	gs.Scenario = scenario
+}
+
+func (gs *GameState) restore(s Snapshot) {
+	gs.Player.Units = map[int]Unit{}
+	for id, unit := range s.Player.Units {
+		gs.Player.Units[id] = unit
+		gs.NextUnitID = max(gs.NextUnitID, unit.ID+1)
+	}
+	gs.Paused = s.Paused
+	gs.NextUnitID = max(gs.NextUnitID, s.NextUnitID)
+}
+
+func sortedUnits(units []Unit) []Unit {
+	sort.Slice(units, func(i, j int) bool { return units[i].ID < units[j].ID })
+	return units
+}
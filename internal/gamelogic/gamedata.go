@@ -23,30 +23,9 @@ type ArmyMove struct {
 	Player     Player
 	Units      []Unit
 	ToLocation Location
-}
-
-type RecognitionOfWar struct {
-	Attacker Player
-	Defender Player
+	// Path is the territories the units travel through, from where they
+	// were to ToLocation.
+	Path []Location
 }
 
 type Location string
-
-func getAllRanks() map[UnitRank]struct{} {
-	return map[UnitRank]struct{}{
-		RankInfantry:  {},
-		RankCavalry:   {},
-		RankArtillery: {},
-	}
-}
-
-func getAllLocations() map[Location]struct{} {
-	return map[Location]struct{}{
-		"americas":   {},
-		"europe":     {},
-		"africa":     {},
-		"asia":       {},
-		"australia":  {},
-		"antarctica": {},
-	}
-}
@@ -2,6 +2,7 @@ package gamelogic
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -18,6 +19,9 @@ func PrintClientHelp() {
 	fmt.Println("    example:")
 	fmt.Println("    spawn europe infantry")
 	fmt.Println("* status")
+	fmt.Println("* scenario")
+	fmt.Println("* save [file]")
+	fmt.Println("* load [file]")
 	fmt.Println("* spam <n>")
 	fmt.Println("    example:")
 	fmt.Println("    spam 5")
@@ -42,10 +46,53 @@ func PrintServerHelp() {
 	fmt.Println("Possible commands:")
 	fmt.Println("* pause")
 	fmt.Println("* resume")
+	fmt.Println("* verify")
+	fmt.Println("* scenario")
+	fmt.Println("* quarantine [list | inspect <id> | replay <id> | delete <id> | purge]")
 	fmt.Println("* quit")
 	fmt.Println("* help")
 }
 
+func PrintScenario(s *Scenario) {
+	fmt.Printf("Scenario: %s\n", s.Name())
+	fmt.Println("Territories:")
+	for _, loc := range s.Map.Territories() {
+		neighbours := []string{}
+		for _, n := range s.Map.Neighbours(loc) {
+			if cost := s.Map.Cost(loc, n); cost != 1 {
+				neighbours = append(neighbours, fmt.Sprintf("%s (cost %v)", n, cost))
+			} else {
+				neighbours = append(neighbours, string(n))
+			}
+		}
+		fmt.Printf("* %s, next to %v\n", loc, neighbours)
+	}
+	if limit := s.Map.MaxMoveCost(); limit > 1 {
+		fmt.Printf("A move can cost up to %v\n", limit)
+	}
+	fmt.Println("Ranks:")
+	for _, rank := range s.Ranks() {
+		fmt.Printf("* %s, power %v\n", rank, s.Power([]Unit{{Rank: rank}}))
+	}
+	if units := s.StartingUnits(); len(units) > 0 {
+		fmt.Println("Every player starts with:")
+		for _, su := range units {
+			location := su.Location
+			if location == "" {
+				location = "their home territory"
+			}
+			fmt.Printf("* %v %s in %s\n", su.Count, su.Rank, location)
+		}
+	}
+	v := s.Victory()
+	if v.Territories > 0 {
+		fmt.Printf("The first player with units in %v territories wins.\n", v.Territories)
+	}
+	if v.LastStanding {
+		fmt.Println("The last player with units left wins.")
+	}
+}
+
 func GetInput() []string {
 	fmt.Print("> ")
 	scanner := bufio.NewScanner(os.Stdin)
@@ -58,6 +105,22 @@ func GetInput() []string {
 	return strings.Fields(line)
 }
 
+// GetInputContext is GetInput that returns early with nil once ctx is done.
+// It also returns nil when stdin is exhausted; check ctx.Err to tell apart.
+func GetInputContext(ctx context.Context) []string {
+	input := make(chan []string, 1)
+	go func() {
+		input <- GetInput()
+	}()
+	select {
+	case words := <-input:
+		return words
+	case <-ctx.Done():
+		fmt.Println()
+		return nil
+	}
+}
+
 func GetMaliciousLog() string {
 	possibleLogs := []string{
 		"Never interrupt your enemy when he is making a mistake.",
@@ -77,7 +140,7 @@ func PrintQuit() {
 }
 
 func (gs *GameState) CommandStatus() {
-	if gs.isPaused() {
+	if gs.IsPaused() {
 		fmt.Println("The game is paused.")
 		return
 	} else {
@@ -1,13 +1,23 @@
 package gamelogic
 
 import (
+	"fmt"
 	"sync"
 )
 
 type GameState struct {
-	Player Player
-	Paused bool
-	mu     *sync.RWMutex
+	Player   Player
+	Paused   bool
+	Scenario *Scenario
+	// GameID tells games on the same server apart.
+	GameID string
+	// NextUnitID is the ID the next spawned unit gets. IDs only ever go up,
+	// so a unit that dies never has its ID handed out again.
+	NextUnitID int
+	mu         *sync.RWMutex
+
+	events EventLog
+	seq    int
 }
 
 func NewGameState(username string) *GameState {
@@ -16,49 +26,62 @@ func NewGameState(username string) *GameState {
 			Username: username,
 			Units:    map[int]Unit{},
 		},
-		Paused: false,
-		mu:     &sync.RWMutex{},
+		Paused:     false,
+		Scenario:   DefaultScenario(),
+		NextUnitID: 1,
+		mu:         &sync.RWMutex{},
 	}
 }
 
 func (gs *GameState) resumeGame() {
-	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	gs.Paused = false
+	gs.record(Event{Type: EventResumed})
 }
 
 func (gs *GameState) pauseGame() {
-	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	gs.Paused = true
+	gs.record(Event{Type: EventPaused})
 }
 
-func (gs *GameState) isPaused() bool {
+func (gs *GameState) IsPaused() bool {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
 	return gs.Paused
 }
 
-func (gs *GameState) addUnit(u Unit) {
-	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	gs.Player.Units[u.ID] = u
+// JoinGame takes on the ID and scenario of the game the server is running.
+func (gs *GameState) JoinGame(info GameInfo) error {
+	scenario, err := NewScenario(info.Scenario)
+	if err != nil {
+		return fmt.Errorf("invalid scenario: %w", err)
+	}
+	info.Scenario = scenario.Data()
+	gs.record(Event{Type: EventGameJoined, Game: &info})
+	return nil
 }
 
-func (gs *GameState) removeUnitsInLocation(loc Location) {
+func (gs *GameState) getScenario() *Scenario {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.Scenario
+}
+
+// addNewUnit gives u the next unit ID and adds it.
+func (gs *GameState) addNewUnit(u Unit) Unit {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	for k, v := range gs.Player.Units {
-		if v.Location == loc {
-			delete(gs.Player.Units, k)
-		}
+	u.ID = gs.NextUnitID
+	gs.recordLocked(Event{Type: EventUnitSpawned, Units: []Unit{u}})
+	return u
+}
+
+func (gs *GameState) removeUnits(units []Unit) {
+	if len(units) == 0 {
+		return
 	}
+	gs.record(Event{Type: EventUnitsRemoved, Units: sortedUnits(append([]Unit{}, units...))})
 }
 
 func (gs *GameState) UpdateUnit(u Unit) {
-	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	gs.Player.Units[u.ID] = u
+	gs.record(Event{Type: EventUnitsMoved, Units: []Unit{u}})
 }
 
 func (gs *GameState) GetUsername() string {
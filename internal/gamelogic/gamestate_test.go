@@ -0,0 +1,215 @@
+package gamelogic
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type memoryLog []Event
+
+func (l *memoryLog) Append(e Event) error {
+	*l = append(*l, e)
+	return nil
+}
+
+// newPlayer starts alice's game in a skirmish, recording its events.
+func newPlayer(t *testing.T, log EventLog) *GameState {
+	t.Helper()
+	gs := NewGameState("alice")
+	if log != nil {
+		gs.RecordTo(log)
+	}
+	if err := gs.JoinGame(GameInfo{ID: "game", Scenario: skirmish(t).Data()}); err != nil {
+		t.Fatal(err)
+	}
+	return gs
+}
+
+// spawnBoth spawns a unit on the client and tells the world about it, as the
+// client does.
+func spawnBoth(t *testing.T, gs *GameState, w *World, rank UnitRank, loc Location) Unit {
+	t.Helper()
+	unit, err := gs.spawn(loc, rank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spawn(t, w, gs.GetUsername(), unit.ID, unit.Rank, unit.Location)
+	return unit
+}
+
+func TestUnitIDsAreNeverReused(t *testing.T) {
+	var log memoryLog
+	gs := newPlayer(t, &log)
+	w := NewWorld(skirmish(t))
+	seen := map[int]bool{}
+	use := func(unit Unit) {
+		t.Helper()
+		if seen[unit.ID] {
+			t.Fatalf("unit id %v was handed out twice", unit.ID)
+		}
+		seen[unit.ID] = true
+	}
+
+	for range 3 {
+		use(spawnBoth(t, gs, w, RankInfantry, "north"))
+	}
+
+	// bob wipes out alice's units in north
+	spawn(t, w, "bob", 1, RankArtillery, "east")
+	results, err := w.Move(ArmyMove{
+		Player:     Player{Username: "bob"},
+		Units:      []Unit{{ID: 1, Rank: RankArtillery}},
+		ToLocation: "north",
+		Path:       []Location{"east", "north"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Killed["alice"]) != 3 {
+		t.Fatalf("war results = %+v, want alice to lose 3 units", results)
+	}
+	gs.HandleWarResult(results[0])
+	if n := len(gs.GetPlayerSnap().Units); n != 0 {
+		t.Fatalf("alice has %v units after losing them all", n)
+	}
+
+	use(spawnBoth(t, gs, w, RankCavalry, "south"))
+
+	// the world refuses the ids of dead units and ids that go backwards
+	for _, id := range []int{0, 1, 3} {
+		if err := w.Spawn(SpawnedUnit{Username: "alice", Unit: Unit{ID: id, Rank: RankInfantry, Location: "west"}}); err == nil {
+			t.Errorf("world accepted unit %v again", id)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "alice.snapshot.json")
+	if err := gs.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := newPlayer(t, nil)
+	snapshot, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	use(spawnBoth(t, loaded, w, RankInfantry, "west"))
+
+	replayed, err := Replay(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.NextUnitID != gs.NextUnitID {
+		t.Errorf("replay has next id %v, the game had %v", replayed.NextUnitID, gs.NextUnitID)
+	}
+	unit, err := replayed.spawn("west", RankInfantry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.ID <= 4 {
+		t.Errorf("replayed game handed out id %v, at or below the last one it spawned", unit.ID)
+	}
+}
+
+// spawnOrReject sends a spawn to the world and, if it is refused, hands the
+// rejection back to the client, as the server does. It returns the unit the
+// client spawned again, if any.
+func spawnOrReject(t *testing.T, gs *GameState, w *World, unit Unit, restored bool) (Unit, bool) {
+	t.Helper()
+	s := SpawnedUnit{Username: gs.GetUsername(), Unit: unit, Restored: restored}
+	err := w.Spawn(s)
+	if err == nil {
+		return Unit{}, false
+	}
+	return gs.HandleSpawnRejection(w.RejectSpawn(s, err))
+}
+
+func TestStaleClientResyncsUnitIDs(t *testing.T) {
+	w := NewWorld(skirmish(t))
+	spawn(t, w, "alice", 1, RankInfantry, "north")
+	spawn(t, w, "alice", 2, RankInfantry, "north")
+	spawn(t, w, "alice", 3, RankCavalry, "east")
+
+	// alice lost her snapshot, so her new game starts again from id 1
+	var log memoryLog
+	gs := newPlayer(t, &log)
+	fresh, err := gs.spawn("south", RankArtillery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	respawned, ok := spawnOrReject(t, gs, w, fresh, false)
+	if !ok {
+		t.Fatal("fresh unit with a used id was not spawned again")
+	}
+	if respawned.ID != 4 || respawned.Rank != RankArtillery || respawned.Location != "south" {
+		t.Errorf("spawned again as %+v, want artillery 4 in south", respawned)
+	}
+	if _, ok := spawnOrReject(t, gs, w, respawned, false); ok {
+		t.Error("world refused the unit spawned again")
+	}
+	// the world's unit 1 takes the place of the one that clashed with it
+	if unit, _ := gs.GetUnit(1); unit.Rank != RankInfantry || unit.Location != "north" {
+		t.Errorf("unit 1 = %+v, want the world's infantry in north", unit)
+	}
+	if next, _ := gs.spawn("west", RankInfantry); next.ID != 5 {
+		t.Errorf("next unit got id %v, want 5", next.ID)
+	}
+
+	replayed, err := Replay(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.NextUnitID != gs.NextUnitID || !reflect.DeepEqual(replayed.GetPlayerSnap(), gs.GetPlayerSnap()) {
+		t.Errorf("replay ended with next id %v and %+v, the game with %v and %+v",
+			replayed.NextUnitID, replayed.GetPlayerSnap(), gs.NextUnitID, gs.GetPlayerSnap())
+	}
+}
+
+func TestRestoredUnitsAreAnnouncedAgain(t *testing.T) {
+	w := NewWorld(skirmish(t))
+	spawn(t, w, "alice", 1, RankInfantry, "north")
+	spawn(t, w, "alice", 2, RankCavalry, "east")
+
+	// the snapshot is from before unit 2 died and unit 1 moved
+	gs := newPlayer(t, nil)
+	err := gs.Restore(Snapshot{
+		Player: Player{Username: "alice", Units: map[int]Unit{
+			1: {ID: 1, Rank: RankInfantry, Location: "north"},
+			2: {ID: 2, Rank: RankCavalry, Location: "east"},
+			3: {ID: 3, Rank: RankCavalry, Location: "west"},
+		}},
+		NextUnitID: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spawn(t, w, "bob", 1, RankArtillery, "south")
+	moves := []ArmyMove{
+		{Player: Player{Username: "alice"}, Units: []Unit{{ID: 1, Rank: RankInfantry}}, ToLocation: "centre", Path: []Location{"north", "centre"}},
+		{Player: Player{Username: "bob"}, Units: []Unit{{ID: 1, Rank: RankArtillery}}, ToLocation: "east", Path: []Location{"south", "east"}},
+	}
+	for _, move := range moves {
+		if _, err := w.Move(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []int{1, 2, 3} {
+		unit, _ := gs.GetUnit(id)
+		if _, ok := spawnOrReject(t, gs, w, unit, true); ok {
+			t.Errorf("restored unit %v was spawned again", id)
+		}
+	}
+	want := map[int]Unit{
+		1: {ID: 1, Rank: RankInfantry, Location: "centre"},
+		3: {ID: 3, Rank: RankCavalry, Location: "west"},
+	}
+	if got := gs.GetPlayerSnap().Units; !reflect.DeepEqual(got, want) {
+		t.Errorf("units after announcing = %+v, want %+v", got, want)
+	}
+	if units := w.Units("alice", []int{3}); len(units) != 1 {
+		t.Error("world does not have the restored unit it had not seen")
+	}
+}
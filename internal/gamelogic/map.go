@@ -0,0 +1,264 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MapData is a map as stored in a scenario file. Edges are two-way.
+type MapData struct {
+	Territories []Location `json:"territories" yaml:"territories"`
+	Edges       []Edge     `json:"edges" yaml:"edges"`
+	// MaxMoveCost is how far a move can take units, adding up the costs of
+	// the edges they cross. 0 means 1, a single edge of the default cost.
+	MaxMoveCost int `json:"max_move_cost,omitempty" yaml:"max_move_cost"`
+}
+
+// Edge joins two territories. In a file it is ["from", "to"], or
+// ["from", "to", cost] for an edge that costs more than 1 to cross.
+type Edge struct {
+	From Location
+	To   Location
+	// Cost is 1 when not set.
+	Cost int
+}
+
+func (e Edge) cost() int {
+	if e.Cost == 0 {
+		return 1
+	}
+	return e.Cost
+}
+
+func (e Edge) MarshalJSON() ([]byte, error) {
+	if e.Cost == 0 {
+		return json.Marshal([]any{e.From, e.To})
+	}
+	return json.Marshal([]any{e.From, e.To, e.Cost})
+}
+
+func (e *Edge) UnmarshalJSON(b []byte) error {
+	var fields []json.RawMessage
+	err := json.Unmarshal(b, &fields)
+	if err != nil {
+		return err
+	}
+	if len(fields) != 2 && len(fields) != 3 {
+		return fmt.Errorf("edge %s is not [from, to] or [from, to, cost]", b)
+	}
+	var edge Edge
+	if err := json.Unmarshal(fields[0], &edge.From); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[1], &edge.To); err != nil {
+		return err
+	}
+	if len(fields) == 3 {
+		if err := json.Unmarshal(fields[2], &edge.Cost); err != nil {
+			return err
+		}
+	}
+	*e = edge
+	return nil
+}
+
+func (e *Edge) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.SequenceNode || (len(value.Content) != 2 && len(value.Content) != 3) {
+		return fmt.Errorf("line %d: edge is not [from, to] or [from, to, cost]", value.Line)
+	}
+	var edge Edge
+	if err := value.Content[0].Decode(&edge.From); err != nil {
+		return err
+	}
+	if err := value.Content[1].Decode(&edge.To); err != nil {
+		return err
+	}
+	if len(value.Content) == 3 {
+		if err := value.Content[2].Decode(&edge.Cost); err != nil {
+			return err
+		}
+	}
+	*e = edge
+	return nil
+}
+
+// Map is the graph of territories units move over.
+type Map struct {
+	data MapData
+	// adjacent holds the cost of every edge, both ways round.
+	adjacent map[Location]map[Location]int
+}
+
+// NewMap checks data and builds a map from it, reporting every problem at
+// once.
+func NewMap(data MapData) (*Map, error) {
+	m, errs := newMap(data)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return m, nil
+}
+
+func newMap(data MapData) (*Map, []error) {
+	m := &Map{data: data, adjacent: map[Location]map[Location]int{}}
+	var errs []error
+	if len(data.Territories) == 0 {
+		errs = append(errs, errors.New("map has no territories"))
+	}
+	for _, t := range data.Territories {
+		if t == "" {
+			errs = append(errs, errors.New("territory with no name"))
+			continue
+		}
+		if m.Has(t) {
+			errs = append(errs, fmt.Errorf("territory %s is listed twice", t))
+			continue
+		}
+		m.adjacent[t] = map[Location]int{}
+	}
+	if data.MaxMoveCost < 0 {
+		errs = append(errs, fmt.Errorf("max_move_cost is negative: %v", data.MaxMoveCost))
+	}
+	for _, e := range data.Edges {
+		a, b := e.From, e.To
+		switch {
+		case !m.Has(a):
+			errs = append(errs, fmt.Errorf("edge %s-%s: unknown territory %s", a, b, a))
+		case !m.Has(b):
+			errs = append(errs, fmt.Errorf("edge %s-%s: unknown territory %s", a, b, b))
+		case a == b:
+			errs = append(errs, fmt.Errorf("edge %s-%s joins a territory to itself", a, b))
+		case e.Cost < 0:
+			errs = append(errs, fmt.Errorf("edge %s-%s has negative cost %v", a, b, e.Cost))
+		default:
+			m.adjacent[a][b] = e.cost()
+			m.adjacent[b][a] = e.cost()
+		}
+	}
+	return m, errs
+}
+
+func (m *Map) Data() MapData {
+	return m.data
+}
+
+func (m *Map) Has(loc Location) bool {
+	_, ok := m.adjacent[loc]
+	return ok
+}
+
+func (m *Map) Territories() []Location {
+	locs := make([]Location, 0, len(m.adjacent))
+	for loc := range m.adjacent {
+		locs = append(locs, loc)
+	}
+	sort.Slice(locs, func(i, j int) bool { return locs[i] < locs[j] })
+	return locs
+}
+
+func (m *Map) Neighbours(loc Location) []Location {
+	locs := []Location{}
+	for n := range m.adjacent[loc] {
+		locs = append(locs, n)
+	}
+	sort.Slice(locs, func(i, j int) bool { return locs[i] < locs[j] })
+	return locs
+}
+
+// Cost is what crossing the edge between two adjacent territories costs.
+func (m *Map) Cost(from, to Location) int {
+	return m.adjacent[from][to]
+}
+
+// MaxMoveCost is the most a single move can cost.
+func (m *Map) MaxMoveCost() int {
+	return max(m.data.MaxMoveCost, 1)
+}
+
+// CheckPath reports whether path is a valid move: a walk along edges from
+// where the units are to where they stop, costing no more than MaxMoveCost.
+func (m *Map) CheckPath(path []Location) error {
+	if len(path) == 0 {
+		return errors.New("move has no path")
+	}
+	if len(path) == 1 {
+		return fmt.Errorf("a move has to leave %s", path[0])
+	}
+	for _, loc := range path {
+		if !m.Has(loc) {
+			return fmt.Errorf("%s is not a valid location", loc)
+		}
+	}
+	cost := 0
+	for i := 1; i < len(path); i++ {
+		from, to := path[i-1], path[i]
+		c, ok := m.adjacent[from][to]
+		if !ok {
+			return fmt.Errorf("%s is not adjacent to %s", to, from)
+		}
+		cost += c
+	}
+	if cost > m.MaxMoveCost() {
+		return fmt.Errorf("the path %v costs %v, more than the %v a move can", path, cost, m.MaxMoveCost())
+	}
+	return nil
+}
+
+// Route finds the cheapest path for a move from one territory to another.
+// Of paths that cost the same, it picks the one through territories that
+// come first alphabetically, so every client routes the same way.
+func (m *Map) Route(from, to Location) ([]Location, error) {
+	for _, loc := range []Location{from, to} {
+		if !m.Has(loc) {
+			return nil, fmt.Errorf("%s is not a valid location", loc)
+		}
+	}
+	if from == to {
+		return nil, fmt.Errorf("the units are already in %s", to)
+	}
+
+	cost := map[Location]int{from: 0}
+	prev := map[Location]Location{}
+	done := map[Location]bool{}
+	for {
+		next, found := Location(""), false
+		for _, loc := range m.Territories() {
+			c, ok := cost[loc]
+			if ok && !done[loc] && (!found || c < cost[next]) {
+				next, found = loc, true
+			}
+		}
+		if !found || next == to {
+			break
+		}
+		done[next] = true
+		for _, n := range m.Neighbours(next) {
+			c := cost[next] + m.adjacent[next][n]
+			if old, ok := cost[n]; !ok || c < old {
+				cost[n] = c
+				prev[n] = next
+			}
+		}
+	}
+
+	c, ok := cost[to]
+	if !ok {
+		return nil, fmt.Errorf("there is no way from %s to %s", from, to)
+	}
+	if c > m.MaxMoveCost() {
+		if _, adjacent := m.adjacent[from][to]; !adjacent && m.MaxMoveCost() == 1 {
+			return nil, fmt.Errorf("%s is not adjacent to %s", to, from)
+		}
+		return nil, fmt.Errorf("%s is %v away from %s, more than the %v a move can go", to, c, from, m.MaxMoveCost())
+	}
+	path := []Location{to}
+	for loc := to; loc != from; {
+		loc = prev[loc]
+		path = append([]Location{loc}, path...)
+	}
+	return path, nil
+}
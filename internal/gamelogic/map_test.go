@@ -0,0 +1,111 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// costlyMap is a square a-b-c-d with a shortcut from a to c that costs more
+// than going round through b.
+func costlyMap(t *testing.T) *Map {
+	t.Helper()
+	m, err := NewMap(MapData{
+		Territories: []Location{"a", "b", "c", "d"},
+		Edges:       []Edge{{From: "a", To: "b"}, {From: "b", To: "c"}, {From: "a", To: "c", Cost: 3}, {From: "c", To: "d"}},
+		MaxMoveCost: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMapCheckPath(t *testing.T) {
+	m := costlyMap(t)
+	tests := []struct {
+		path []Location
+		want string
+	}{
+		{[]Location{"a", "b"}, ""},
+		{[]Location{"a", "b", "c"}, ""},
+		{[]Location{"c", "a"}, "costs 3"},
+		{[]Location{"a", "b", "c", "d"}, "costs 3"},
+		{[]Location{"a", "d"}, "d is not adjacent to a"},
+		{[]Location{"a", "b", "x"}, "x is not a valid location"},
+		{[]Location{"a"}, "has to leave a"},
+		{nil, "no path"},
+	}
+	for _, tt := range tests {
+		err := m.CheckPath(tt.path)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("CheckPath(%v): %v", tt.path, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("CheckPath(%v) = %v, want an error mentioning %q", tt.path, err, tt.want)
+		}
+	}
+}
+
+func TestMapRoute(t *testing.T) {
+	m := costlyMap(t)
+	path, err := m.Route("a", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Location{"a", "b", "c"}; !reflect.DeepEqual(path, want) {
+		t.Errorf("route from a to c = %v, want the cheaper %v", path, want)
+	}
+	if err := m.CheckPath(path); err != nil {
+		t.Errorf("route %v does not pass CheckPath: %v", path, err)
+	}
+	if _, err := m.Route("a", "d"); err == nil || !strings.Contains(err.Error(), "3 away") {
+		t.Errorf("route from a to d: %v, want it to be too far", err)
+	}
+	if _, err := m.Route("a", "a"); err == nil {
+		t.Error("routed from a to itself")
+	}
+
+	// with the default costs a move is a single step
+	s := skirmish(t)
+	if path, err := s.Map.Route("north", "east"); err != nil || !reflect.DeepEqual(path, []Location{"north", "east"}) {
+		t.Errorf("route from north to east = %v, %v", path, err)
+	}
+	if _, err := s.Map.Route("north", "south"); err == nil || !strings.Contains(err.Error(), "not adjacent") {
+		t.Errorf("route from north to south: %v, want not adjacent", err)
+	}
+}
+
+func TestEdgeJSON(t *testing.T) {
+	var edges []Edge
+	err := json.Unmarshal([]byte(`[["a", "b"], ["b", "c", 3]]`), &edges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Edge{{From: "a", To: "b"}, {From: "b", To: "c", Cost: 3}}
+	if !reflect.DeepEqual(edges, want) {
+		t.Errorf("got %+v, want %+v", edges, want)
+	}
+	b, err := json.Marshal(edges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `[["a","b"],["b","c",3]]` {
+		t.Errorf("marshalled as %s", b)
+	}
+	if err := json.Unmarshal([]byte(`[["a"]]`), &edges); err == nil {
+		t.Error("accepted an edge with one end")
+	}
+}
+
+func TestNewMapRejectsNegativeCosts(t *testing.T) {
+	_, err := NewMap(MapData{
+		Territories: []Location{"a", "b"},
+		Edges:       []Edge{{From: "a", To: "b", Cost: -1}},
+		MaxMoveCost: -1,
+	})
+	if err == nil || !strings.Contains(err.Error(), "negative cost") || !strings.Contains(err.Error(), "max_move_cost") {
+		t.Errorf("err = %v, want both negative costs reported", err)
+	}
+}
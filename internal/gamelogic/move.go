@@ -29,36 +29,37 @@ func (gs *GameState) HandleMove(move ArmyMove) MoveOutcome {
 		return MoveOutcomeSamePlayer
 	}
 
-	overlappingLocation := getOverlappingLocation(player, move.Player)
-	if overlappingLocation != "" {
-		fmt.Printf("You have units in %s! You are at war with %s!\n", overlappingLocation, move.Player.Username)
-		return MoveOutcomeMakeWar
+	for _, unit := range player.Units {
+		if unit.Location == move.ToLocation {
+			fmt.Printf("You have units in %s! You are at war with %s!\n", move.ToLocation, move.Player.Username)
+			return MoveOutcomeMakeWar
+		}
 	}
 	fmt.Printf("You are safe from %s's units.\n", move.Player.Username)
 	return MoveOutComeSafe
 }
 
-func getOverlappingLocation(p1 Player, p2 Player) Location {
-	for _, u1 := range p1.Units {
-		for _, u2 := range p2.Units {
-			if u1.Location == u2.Location {
-				return u1.Location
-			}
-		}
+// HandleMoveRejection puts units back where the server says they are.
+func (gs *GameState) HandleMoveRejection(mr MoveRejection) {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Println("==== Move Rejected ====")
+	fmt.Printf("The server rejected your move: %s\n", mr.Reason)
+	for _, unit := range mr.Units {
+		gs.UpdateUnit(unit)
+		fmt.Printf("* %v is back in %s\n", unit.ID, unit.Location)
 	}
-	return ""
 }
 
 func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
-	if gs.isPaused() {
+	if gs.IsPaused() {
 		return ArmyMove{}, errors.New("the game is paused, you can not move units")
 	}
 	if len(words) < 3 {
 		return ArmyMove{}, errors.New("usage: move <location> <unitID> <unitID> <unitID> etc")
 	}
 	newLocation := Location(words[1])
-	locations := getAllLocations()
-	if _, ok := locations[newLocation]; !ok {
+	if !gs.getScenario().Map.Has(newLocation) {
 		return ArmyMove{}, fmt.Errorf("error: %s is not a valid location", newLocation)
 	}
 	unitIDs := []int{}
@@ -71,21 +72,31 @@ func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
 		unitIDs = append(unitIDs, unitID)
 	}
 
-	newUnits := []Unit{}
+	units := []Unit{}
 	for _, unitID := range unitIDs {
 		unit, ok := gs.GetUnit(unitID)
 		if !ok {
 			return ArmyMove{}, fmt.Errorf("error: unit with ID %v not found", unitID)
 		}
-		unit.Location = newLocation
-		gs.UpdateUnit(unit)
-		newUnits = append(newUnits, unit)
+		if len(units) > 0 && unit.Location != units[0].Location {
+			return ArmyMove{}, errors.New("error: units moving together must start in the same location")
+		}
+		units = append(units, unit)
+	}
+	path, err := gs.getScenario().Map.Route(units[0].Location, newLocation)
+	if err != nil {
+		return ArmyMove{}, fmt.Errorf("error: %v", err)
 	}
 
+	for i := range units {
+		units[i].Location = newLocation
+		gs.UpdateUnit(units[i])
+	}
 	mv := ArmyMove{
 		ToLocation: newLocation,
-		Units:      newUnits,
+		Units:      units,
 		Player:     gs.GetPlayerSnap(),
+		Path:       path,
 	}
 	fmt.Printf("Moved %v units to %s\n", len(mv.Units), mv.ToLocation)
 	return mv, nil
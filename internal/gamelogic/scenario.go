@@ -0,0 +1,237 @@
+package gamelogic
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed scenarios/*.json
+var builtinScenarios embed.FS
+
+// DefaultScenarioName is the scenario played when none is chosen: the six
+// continents and three ranks of the original game.
+const DefaultScenarioName = "classic"
+
+// ScenarioData is a scenario as stored in a file and sent to clients.
+type ScenarioData struct {
+	Name string  `json:"name" yaml:"name"`
+	Map  MapData `json:"map" yaml:"map"`
+	// Ranks maps every rank that can be spawned to its power in battle.
+	Ranks         map[UnitRank]int `json:"ranks" yaml:"ranks"`
+	StartingUnits []StartingUnits  `json:"starting_units" yaml:"starting_units"`
+	Victory       VictoryRules     `json:"victory" yaml:"victory"`
+}
+
+// StartingUnits are spawned for every player when they join. Units without a
+// location start in the player's home territory, picked at random.
+type StartingUnits struct {
+	Rank     UnitRank `json:"rank" yaml:"rank"`
+	Location Location `json:"location,omitempty" yaml:"location"`
+	Count    int      `json:"count" yaml:"count"`
+}
+
+// VictoryRules decide when the game is over. With neither set it never is.
+type VictoryRules struct {
+	// Territories wins the game for the first player with units in this
+	// many territories.
+	Territories int `json:"territories,omitempty" yaml:"territories"`
+	// LastStanding wins the game for the only player left with units.
+	LastStanding bool `json:"last_standing,omitempty" yaml:"last_standing"`
+}
+
+// Scenario is a validated ScenarioData.
+type Scenario struct {
+	data ScenarioData
+	Map  *Map
+}
+
+// NewScenario checks data and builds a scenario from it, reporting every
+// problem at once.
+func NewScenario(data ScenarioData) (*Scenario, error) {
+	var errs []error
+	if data.Name == "" {
+		errs = append(errs, errors.New("name: missing"))
+	}
+
+	m, mapErrs := newMap(data.Map)
+	for _, err := range mapErrs {
+		errs = append(errs, fmt.Errorf("map: %w", err))
+	}
+
+	if len(data.Ranks) == 0 {
+		errs = append(errs, errors.New("ranks: there are none"))
+	}
+	for _, rank := range sortedRanks(data.Ranks) {
+		if rank == "" {
+			errs = append(errs, errors.New("ranks: rank with no name"))
+		}
+		if data.Ranks[rank] < 0 {
+			errs = append(errs, fmt.Errorf("ranks: %s has negative power %v", rank, data.Ranks[rank]))
+		}
+	}
+
+	for i, su := range data.StartingUnits {
+		if _, ok := data.Ranks[su.Rank]; !ok {
+			errs = append(errs, fmt.Errorf("starting_units[%d]: unknown rank %q", i, su.Rank))
+		}
+		if su.Location != "" && !m.Has(su.Location) {
+			errs = append(errs, fmt.Errorf("starting_units[%d]: unknown location %q", i, su.Location))
+		}
+		if su.Count < 1 {
+			errs = append(errs, fmt.Errorf("starting_units[%d]: count must be at least 1, not %v", i, su.Count))
+		}
+	}
+
+	territories := len(m.adjacent)
+	if v := data.Victory.Territories; v < 0 || v > territories {
+		errs = append(errs, fmt.Errorf("victory.territories: %v is not between 0 and the %v territories on the map", v, territories))
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &Scenario{data: data, Map: m}, nil
+}
+
+// ParseScenario reads a scenario from JSON, rejecting unknown fields.
+func ParseScenario(b []byte) (*Scenario, error) {
+	var data ScenarioData
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&data)
+	if err != nil {
+		return nil, jsonError(b, err)
+	}
+	return NewScenario(data)
+}
+
+// ParseScenarioYAML reads a scenario from YAML, rejecting unknown fields.
+func ParseScenarioYAML(b []byte) (*Scenario, error) {
+	var data ScenarioData
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	err := dec.Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+	return NewScenario(data)
+}
+
+// LoadScenario loads a built-in scenario by name, or a scenario file by path.
+// Files ending in .yaml or .yml are read as YAML, any others as JSON.
+func LoadScenario(nameOrPath string) (*Scenario, error) {
+	b, err := builtinScenarios.ReadFile(path.Join("scenarios", nameOrPath+".json"))
+	source := "built-in scenario " + nameOrPath
+	parse := ParseScenario
+	if err != nil {
+		b, err = os.ReadFile(nameOrPath)
+		if err != nil {
+			return nil, fmt.Errorf("no built-in scenario %s (%s), and reading it as a file: %v", nameOrPath, strings.Join(BuiltinScenarios(), ", "), err)
+		}
+		source = nameOrPath
+		switch strings.ToLower(filepath.Ext(nameOrPath)) {
+		case ".yaml", ".yml":
+			parse = ParseScenarioYAML
+		}
+	}
+	s, err := parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scenario %s:\n%w", source, err)
+	}
+	return s, nil
+}
+
+// BuiltinScenarios lists the names LoadScenario accepts without a file.
+func BuiltinScenarios() []string {
+	entries, _ := builtinScenarios.ReadDir("scenarios")
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	return names
+}
+
+// DefaultScenario is the built-in classic scenario. It is compiled in and
+// TestBuiltinScenarios checks it, so it is not validated again here.
+func DefaultScenario() *Scenario {
+	b, _ := builtinScenarios.ReadFile(path.Join("scenarios", DefaultScenarioName+".json"))
+	var data ScenarioData
+	json.Unmarshal(b, &data)
+	m, _ := newMap(data.Map)
+	return &Scenario{data: data, Map: m}
+}
+
+// jsonError points at the line and column of a decoding error.
+func jsonError(b []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+	before := b[:min(int(offset), len(b))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Errorf("line %d, column %d: %v", line, col, err)
+}
+
+func (s *Scenario) Name() string {
+	return s.data.Name
+}
+
+func (s *Scenario) Data() ScenarioData {
+	return s.data
+}
+
+func (s *Scenario) HasRank(rank UnitRank) bool {
+	_, ok := s.data.Ranks[rank]
+	return ok
+}
+
+// Ranks lists the ranks from weakest to strongest.
+func (s *Scenario) Ranks() []UnitRank {
+	ranks := sortedRanks(s.data.Ranks)
+	sort.SliceStable(ranks, func(i, j int) bool { return s.data.Ranks[ranks[i]] < s.data.Ranks[ranks[j]] })
+	return ranks
+}
+
+func (s *Scenario) StartingUnits() []StartingUnits {
+	return s.data.StartingUnits
+}
+
+func (s *Scenario) Victory() VictoryRules {
+	return s.data.Victory
+}
+
+// Power is the strength of units in battle.
+func (s *Scenario) Power(units []Unit) int {
+	power := 0
+	for _, unit := range units {
+		power += s.data.Ranks[unit.Rank]
+	}
+	return power
+}
+
+func sortedRanks(ranks map[UnitRank]int) []UnitRank {
+	names := make([]UnitRank, 0, len(ranks))
+	for rank := range ranks {
+		names = append(names, rank)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
@@ -0,0 +1,109 @@
+package gamelogic
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinScenarios(t *testing.T) {
+	names := BuiltinScenarios()
+	if len(names) == 0 {
+		t.Fatal("no built-in scenarios")
+	}
+	for _, name := range names {
+		if _, err := LoadScenario(name); err != nil {
+			t.Errorf("built-in scenario %s: %v", name, err)
+		}
+	}
+
+	// DefaultScenario skips validation, relying on this test
+	want, err := LoadScenario(DefaultScenarioName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := DefaultScenario()
+	if !reflect.DeepEqual(got.Data(), want.Data()) || !reflect.DeepEqual(got.Map, want.Map) {
+		t.Errorf("DefaultScenario differs from the %s scenario", DefaultScenarioName)
+	}
+}
+
+const skirmishYAML = `name: skirmish
+map:
+  territories: [north, east, south, west, centre]
+  edges:
+    - [north, east]
+    - [east, south]
+    - [south, west]
+    - [west, north]
+    - [centre, north]
+    - [centre, east]
+    - [centre, south]
+    - [centre, west]
+ranks:
+  infantry: 1
+  cavalry: 4
+  artillery: 8
+starting_units:
+  - {rank: infantry, count: 3}
+  - {rank: cavalry, count: 1}
+victory:
+  territories: 4
+  last_standing: true
+`
+
+func writeScenario(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadScenarioYAML(t *testing.T) {
+	want := skirmish(t).Data()
+	for _, name := range []string{"skirmish.yaml", "skirmish.yml"} {
+		s, err := LoadScenario(writeScenario(t, name, skirmishYAML))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(s.Data(), want) {
+			t.Errorf("%s = %+v, want the built-in skirmish %+v", name, s.Data(), want)
+		}
+	}
+
+	costly := strings.Replace(skirmishYAML, "[centre, west]", "[centre, west, 3]", 1)
+	s, err := LoadScenario(writeScenario(t, "costly.yaml", costly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost := s.Map.Cost("west", "centre"); cost != 3 {
+		t.Errorf("west to centre costs %v, want 3", cost)
+	}
+}
+
+func TestLoadScenarioYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{"unknown field", strings.Replace(skirmishYAML, "ranks:", "rank:", 1), "field rank not found"},
+		{"bad edge", strings.Replace(skirmishYAML, "[north, east]", "[north]", 1), "line 5: edge is not"},
+		{"invalid scenario", strings.Replace(skirmishYAML, "territories: 4", "territories: 9", 1), "victory.territories"},
+	}
+	for _, tt := range tests {
+		_, err := LoadScenario(writeScenario(t, "scenario.yaml", tt.contents))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+
+	// the extension decides, so YAML in a .json file is a JSON error
+	if _, err := LoadScenario(writeScenario(t, "skirmish.json", skirmishYAML)); err == nil {
+		t.Error("YAML in a .json file was accepted")
+	}
+}
@@ -0,0 +1,153 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshot format written by
+// SaveSnapshot. To change the format, bump it and add a migration from the
+// previous version to snapshotMigrations.
+const SnapshotVersion = 1
+
+// snapshotMigrations upgrade a decoded snapshot from the version they are
+// keyed by to the next one.
+var snapshotMigrations = map[int]func(fields map[string]json.RawMessage) error{}
+
+// Snapshot is everything needed to pick a game back up.
+type Snapshot struct {
+	Version    int       `json:"version"`
+	GameID     string    `json:"game_id"`
+	Scenario   string    `json:"scenario"`
+	SavedAt    time.Time `json:"saved_at"`
+	Player     Player    `json:"player"`
+	Paused     bool      `json:"paused"`
+	NextUnitID int       `json:"next_unit_id"`
+}
+
+func (gs *GameState) Snapshot() Snapshot {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.snapshotLocked()
+}
+
+func (gs *GameState) snapshotLocked() Snapshot {
+	units := map[int]Unit{}
+	for id, unit := range gs.Player.Units {
+		units[id] = unit
+	}
+	return Snapshot{
+		Version:    SnapshotVersion,
+		GameID:     gs.GameID,
+		Scenario:   gs.Scenario.Name(),
+		SavedAt:    time.Now().UTC(),
+		Player:     Player{Username: gs.Player.Username, Units: units},
+		Paused:     gs.Paused,
+		NextUnitID: gs.NextUnitID,
+	}
+}
+
+// Restore replaces the player's units and pause flag with a snapshot's. Every
+// unit has to fit the current scenario, and the snapshot has to be of the
+// same player.
+func (gs *GameState) Restore(s Snapshot) error {
+	scenario := gs.getScenario()
+	if s.Player.Username != gs.GetUsername() {
+		return fmt.Errorf("snapshot is of %s, not %s", s.Player.Username, gs.GetUsername())
+	}
+	var errs []error
+	for id, unit := range s.Player.Units {
+		if id != unit.ID {
+			errs = append(errs, fmt.Errorf("unit %v is stored as %v", unit.ID, id))
+		}
+		if !scenario.Map.Has(unit.Location) {
+			errs = append(errs, fmt.Errorf("unit %v is in %s, which is not in scenario %s", unit.ID, unit.Location, scenario.Name()))
+		}
+		if !scenario.HasRank(unit.Rank) {
+			errs = append(errs, fmt.Errorf("unit %v is a(n) %s, which is not in scenario %s", unit.ID, unit.Rank, scenario.Name()))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	gs.record(Event{Type: EventRestored, Snapshot: &s})
+	return nil
+}
+
+// SaveSnapshot writes the game state to path, replacing any earlier snapshot
+// only once the new one is complete.
+func (gs *GameState) SaveSnapshot(path string) error {
+	b, err := json.MarshalIndent(gs.Snapshot(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating snapshot: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing snapshot: %v", err)
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("replacing snapshot: %v", err)
+	}
+	return nil
+}
+
+// LoadSnapshot reads a snapshot, migrating it from older versions of the
+// format.
+func LoadSnapshot(path string) (Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(b, &fields)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("reading snapshot %s: %v", path, jsonError(b, err))
+	}
+
+	var version int
+	err = json.Unmarshal(fields["version"], &version)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("snapshot %s has no version", path)
+	}
+	if version > SnapshotVersion {
+		return Snapshot{}, fmt.Errorf("snapshot %s is version %v, newer than the supported %v", path, version, SnapshotVersion)
+	}
+	for ; version < SnapshotVersion; version++ {
+		migrate, ok := snapshotMigrations[version]
+		if !ok {
+			return Snapshot{}, fmt.Errorf("snapshot %s: no migration from version %v", path, version)
+		}
+		err := migrate(fields)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("snapshot %s: migrating from version %v: %v", path, version, err)
+		}
+	}
+	fields["version"] = json.RawMessage(fmt.Sprint(SnapshotVersion))
+
+	b, err = json.Marshal(fields)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("snapshot %s: %v", path, err)
+	}
+	var s Snapshot
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("snapshot %s: %v", path, err)
+	}
+	return s, nil
+}
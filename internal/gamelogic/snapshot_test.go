@@ -0,0 +1,120 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// v0Snapshot is a hand-written snapshot in a format from before version 1,
+// with the player flattened and the units in a list.
+const v0Snapshot = `{
+  "version": 0,
+  "game_id": "game",
+  "scenario": "skirmish",
+  "username": "alice",
+  "units": [
+    {"ID": 1, "Rank": "infantry", "Location": "north"},
+    {"ID": 4, "Rank": "cavalry", "Location": "east"}
+  ],
+  "paused": true,
+  "next_unit_id": 5
+}`
+
+// migrateV0 turns a version 0 snapshot into version 1.
+func migrateV0(fields map[string]json.RawMessage) error {
+	var username string
+	var units []Unit
+	if err := json.Unmarshal(fields["username"], &username); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields["units"], &units); err != nil {
+		return err
+	}
+	player := Player{Username: username, Units: map[int]Unit{}}
+	for _, unit := range units {
+		player.Units[unit.ID] = unit
+	}
+	b, err := json.Marshal(player)
+	if err != nil {
+		return err
+	}
+	fields["player"] = b
+	delete(fields, "username")
+	delete(fields, "units")
+	return nil
+}
+
+func writeSnapshot(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "alice.snapshot.json")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSnapshotMigrates(t *testing.T) {
+	path := writeSnapshot(t, v0Snapshot)
+	if _, err := LoadSnapshot(path); err == nil || !strings.Contains(err.Error(), "no migration from version 0") {
+		t.Fatalf("loading v0 without a migration: %v", err)
+	}
+
+	snapshotMigrations[0] = migrateV0
+	t.Cleanup(func() { delete(snapshotMigrations, 0) })
+	s, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Snapshot{
+		Version:  SnapshotVersion,
+		GameID:   "game",
+		Scenario: "skirmish",
+		Player: Player{Username: "alice", Units: map[int]Unit{
+			1: {ID: 1, Rank: RankInfantry, Location: "north"},
+			4: {ID: 4, Rank: RankCavalry, Location: "east"},
+		}},
+		Paused:     true,
+		NextUnitID: 5,
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("migrated to %+v, want %+v", s, want)
+	}
+
+	gs := newPlayer(t, nil)
+	if err := gs.Restore(s); err != nil {
+		t.Fatal(err)
+	}
+	if unit, err := gs.spawn("west", RankInfantry); err != nil || unit.ID != 5 {
+		t.Errorf("first spawn after the restore got id %v (%v), want 5", unit.ID, err)
+	}
+}
+
+func TestLoadSnapshotRejectsNewerVersions(t *testing.T) {
+	path := writeSnapshot(t, `{"version": 99}`)
+	if _, err := LoadSnapshot(path); err == nil || !strings.Contains(err.Error(), "newer than the supported") {
+		t.Errorf("loading a newer snapshot: %v", err)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	gs := newPlayer(t, nil)
+	gs.spawn("north", RankInfantry)
+	gs.spawn("east", RankCavalry)
+	path := filepath.Join(t.TempDir(), "alice.snapshot.json")
+	if err := gs.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := gs.Snapshot()
+	s.SavedAt = want.SavedAt
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("loaded %+v, saved %+v", s, want)
+	}
+}
@@ -3,32 +3,84 @@ package gamelogic
 import (
 	"errors"
 	"fmt"
+	"math/rand"
 )
 
-func (gs *GameState) CommandSpawn(words []string) error {
+func (gs *GameState) CommandSpawn(words []string) (Unit, error) {
 	if len(words) < 3 {
-		return errors.New("usage: spawn <location> <rank>")
+		return Unit{}, errors.New("usage: spawn <location> <rank>")
 	}
+	return gs.spawn(Location(words[1]), UnitRank(words[2]))
+}
+
+// SpawnStartingUnits spawns the units every player starts the scenario with.
+func (gs *GameState) SpawnStartingUnits() ([]Unit, error) {
+	scenario := gs.getScenario()
+	territories := scenario.Map.Territories()
+	home := territories[rand.Intn(len(territories))]
 
-	locationName := words[1]
-	locations := getAllLocations()
-	if _, ok := locations[Location(locationName)]; !ok {
-		return fmt.Errorf("error: %s is not a valid location", locationName)
+	units := []Unit{}
+	for _, su := range scenario.StartingUnits() {
+		location := su.Location
+		if location == "" {
+			location = home
+		}
+		for range su.Count {
+			unit, err := gs.spawn(location, su.Rank)
+			if err != nil {
+				return units, err
+			}
+			units = append(units, unit)
+		}
 	}
+	return units, nil
+}
 
-	rank := words[2]
-	units := getAllRanks()
-	if _, ok := units[UnitRank(rank)]; !ok {
-		return fmt.Errorf("error: %s is not a valid unit", rank)
+func (gs *GameState) spawn(location Location, rank UnitRank) (Unit, error) {
+	scenario := gs.getScenario()
+	if !scenario.Map.Has(location) {
+		return Unit{}, fmt.Errorf("error: %s is not a valid location", location)
+	}
+	if !scenario.HasRank(rank) {
+		return Unit{}, fmt.Errorf("error: %s is not a valid unit", rank)
 	}
 
-	id := len(gs.getUnitsSnap()) + 1
-	gs.addUnit(Unit{
-		ID:       id,
-		Rank:     UnitRank(rank),
-		Location: Location(locationName),
+	unit := gs.addNewUnit(Unit{
+		Rank:     rank,
+		Location: location,
 	})
 
-	fmt.Printf("Spawned a(n) %s in %s with id %v\n", rank, locationName, id)
-	return nil
+	fmt.Printf("Spawned a(n) %s in %s with id %v\n", rank, location, unit.ID)
+	return unit, nil
+}
+
+// HandleSpawnRejection brings the game in line with the server after it
+// refused a spawn, and makes sure no unit is given an ID the server has seen
+// before. A unit spawned with such an ID, because the game started from an
+// old snapshot or none at all, is spawned again with a new one, which is
+// returned to be announced.
+func (gs *GameState) HandleSpawnRejection(sr SpawnRejection) (Unit, bool) {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Println("==== Spawn Rejected ====")
+	fmt.Printf("The server rejected unit %v: %s\n", sr.Unit.ID, sr.Reason)
+	gs.record(Event{Type: EventUnitIDsSkipped, NextUnitID: sr.LastUnitID + 1})
+
+	unit, ok := gs.GetUnit(sr.Unit.ID)
+	if !ok {
+		return Unit{}, false
+	}
+	if len(sr.Units) > 0 {
+		gs.UpdateUnit(sr.Units[0])
+		fmt.Printf("* %v is a(n) %s in %s\n", sr.Units[0].ID, sr.Units[0].Rank, sr.Units[0].Location)
+	} else {
+		gs.removeUnits([]Unit{unit})
+		fmt.Printf("* %v is gone\n", unit.ID)
+	}
+	if sr.Restored || sr.Unit.ID > sr.LastUnitID {
+		return Unit{}, false
+	}
+	respawned := gs.addNewUnit(Unit{Rank: unit.Rank, Location: unit.Location})
+	fmt.Printf("* your new %s in %s has id %v\n", respawned.Rank, respawned.Location, respawned.ID)
+	return respawned, true
 }
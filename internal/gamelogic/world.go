@@ -0,0 +1,284 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownUnit is returned for a move of a unit the world has not seen
+// spawned, which may just mean the spawn is still on its way.
+var ErrUnknownUnit = errors.New("unknown unit")
+
+// SpawnedUnit announces a unit a player has spawned.
+type SpawnedUnit struct {
+	Username string
+	Unit     Unit
+	// Restored is set when a client announces a unit again after restoring
+	// a snapshot, rather than spawning it.
+	Restored bool
+}
+
+// SpawnRejection tells a player that the server refused a spawn, and what
+// it knows of their units.
+type SpawnRejection struct {
+	Username string
+	Reason   string
+	Unit     Unit
+	Restored bool
+	// LastUnitID is the highest unit ID the server has seen from the player.
+	LastUnitID int
+	// Units holds the server's copy of a unit with the same ID, if it has
+	// one alive.
+	Units []Unit
+}
+
+// WarResult is the outcome of a battle as decided by the server.
+type WarResult struct {
+	Attacker      string
+	Defender      string
+	Location      Location
+	AttackerPower int
+	DefenderPower int
+	// Winner is empty after a draw.
+	Winner string
+	// Killed holds the units each player lost.
+	Killed map[string][]Unit
+}
+
+// MoveRejection tells a player that the server refused a move, and where the
+// units really are.
+type MoveRejection struct {
+	Username string
+	Reason   string
+	Units    []Unit
+}
+
+// GameInfo is what a client needs to join the game the server is running.
+type GameInfo struct {
+	ID       string
+	Scenario ScenarioData
+}
+
+// GameOver announces the winner of the game.
+type GameOver struct {
+	Winner string
+	Reason string
+}
+
+// World is the server's authoritative view of every player's units. Moves
+// are checked against it rather than against what clients claim to have.
+type World struct {
+	Scenario *Scenario
+
+	mu      sync.Mutex
+	players map[string]Player
+	// lastUnitIDs is the highest unit ID each player has spawned, dead or
+	// alive.
+	lastUnitIDs map[string]int
+}
+
+func NewWorld(s *Scenario) *World {
+	return &World{Scenario: s, players: map[string]Player{}, lastUnitIDs: map[string]int{}}
+}
+
+// Spawn adds a unit to a player, creating the player if needed. Unit IDs
+// must go up with every spawn, so that none is ever used twice, but spawning
+// a unit the world already has exactly as it is changes nothing and is not
+// an error.
+func (w *World) Spawn(s SpawnedUnit) error {
+	if s.Username == "" {
+		return errors.New("spawn has no player")
+	}
+	if !w.Scenario.Map.Has(s.Unit.Location) {
+		return fmt.Errorf("%s is not a valid location", s.Unit.Location)
+	}
+	if !w.Scenario.HasRank(s.Unit.Rank) {
+		return fmt.Errorf("%s is not a valid unit", s.Unit.Rank)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if known, ok := w.players[s.Username].Units[s.Unit.ID]; ok && known == s.Unit {
+		return nil
+	}
+	if last := w.lastUnitIDs[s.Username]; s.Unit.ID <= last {
+		return fmt.Errorf("%s has already spawned unit %v, the next id must be above it", s.Username, last)
+	}
+	player, ok := w.players[s.Username]
+	if !ok {
+		player = Player{Username: s.Username, Units: map[int]Unit{}}
+		w.players[s.Username] = player
+	}
+	player.Units[s.Unit.ID] = s.Unit
+	w.lastUnitIDs[s.Username] = s.Unit.ID
+	return nil
+}
+
+// RejectSpawn explains a refused spawn to the player who made it.
+func (w *World) RejectSpawn(s SpawnedUnit, err error) SpawnRejection {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	rejection := SpawnRejection{
+		Username:   s.Username,
+		Reason:     err.Error(),
+		Unit:       s.Unit,
+		Restored:   s.Restored,
+		LastUnitID: w.lastUnitIDs[s.Username],
+		Units:      []Unit{},
+	}
+	if unit, ok := w.players[s.Username].Units[s.Unit.ID]; ok {
+		rejection.Units = append(rejection.Units, unit)
+	}
+	return rejection
+}
+
+// Move validates a move against the world, applies it and fights every
+// battle it starts in the territory moved to, the mover attacking. Units
+// pass through the territories along the way without fighting.
+func (w *World) Move(move ArmyMove) ([]WarResult, error) {
+	if len(move.Units) == 0 {
+		return nil, errors.New("move has no units")
+	}
+	err := w.Scenario.Map.CheckPath(move.Path)
+	if err != nil {
+		return nil, err
+	}
+	from := move.Path[0]
+	if to := move.Path[len(move.Path)-1]; to != move.ToLocation {
+		return nil, fmt.Errorf("path ends in %s, not %s", to, move.ToLocation)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	attacker, ok := w.players[move.Player.Username]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s has no units", ErrUnknownUnit, move.Player.Username)
+	}
+	for _, claimed := range move.Units {
+		unit, ok := attacker.Units[claimed.ID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s has no unit %v", ErrUnknownUnit, attacker.Username, claimed.ID)
+		}
+		if claimed.Rank != unit.Rank {
+			return nil, fmt.Errorf("unit %v is a(n) %s, not a(n) %s", unit.ID, unit.Rank, claimed.Rank)
+		}
+		if unit.Location != from {
+			return nil, fmt.Errorf("unit %v is in %s, not %s", unit.ID, unit.Location, from)
+		}
+	}
+	for _, claimed := range move.Units {
+		unit := attacker.Units[claimed.ID]
+		unit.Location = move.ToLocation
+		attacker.Units[unit.ID] = unit
+	}
+
+	results := []WarResult{}
+	for _, name := range w.usernames() {
+		if name == attacker.Username {
+			continue
+		}
+		result, ok := w.fight(attacker, w.players[name], move.ToLocation)
+		if ok {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// Units returns where the world has a player's units, leaving out any it does
+// not know.
+func (w *World) Units(username string, ids []int) []Unit {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	units := []Unit{}
+	for _, id := range ids {
+		if unit, ok := w.players[username].Units[id]; ok {
+			units = append(units, unit)
+		}
+	}
+	return units
+}
+
+// Victor returns the player who has won the game under the scenario's
+// victory rules, if anyone has.
+func (w *World) Victor() (winner string, reason string, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	rules := w.Scenario.Victory()
+
+	standing := []string{}
+	for _, name := range w.usernames() {
+		held := map[Location]bool{}
+		for _, unit := range w.players[name].Units {
+			held[unit.Location] = true
+		}
+		if len(held) > 0 {
+			standing = append(standing, name)
+		}
+		if rules.Territories > 0 && len(held) >= rules.Territories {
+			return name, fmt.Sprintf("holds %v territories", len(held)), true
+		}
+	}
+	if rules.LastStanding && len(w.players) > 1 && len(standing) == 1 {
+		return standing[0], "is the last player standing", true
+	}
+	return "", "", false
+}
+
+func (w *World) usernames() []string {
+	names := make([]string, 0, len(w.players))
+	for name := range w.players {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fight resolves a battle between two players if they both have units in
+// location, removing the losing units.
+func (w *World) fight(attacker, defender Player, location Location) (WarResult, bool) {
+	attackerUnits := unitsIn(attacker, location)
+	defenderUnits := unitsIn(defender, location)
+	if len(attackerUnits) == 0 || len(defenderUnits) == 0 {
+		return WarResult{}, false
+	}
+	result := WarResult{
+		Attacker:      attacker.Username,
+		Defender:      defender.Username,
+		Location:      location,
+		AttackerPower: w.Scenario.Power(attackerUnits),
+		DefenderPower: w.Scenario.Power(defenderUnits),
+		Killed:        map[string][]Unit{},
+	}
+	switch {
+	case result.AttackerPower > result.DefenderPower:
+		result.Winner = attacker.Username
+		result.Killed[defender.Username] = defenderUnits
+	case result.DefenderPower > result.AttackerPower:
+		result.Winner = defender.Username
+		result.Killed[attacker.Username] = attackerUnits
+	default:
+		result.Killed[attacker.Username] = attackerUnits
+		result.Killed[defender.Username] = defenderUnits
+	}
+	for _, unit := range result.Killed[attacker.Username] {
+		delete(attacker.Units, unit.ID)
+	}
+	for _, unit := range result.Killed[defender.Username] {
+		delete(defender.Units, unit.ID)
+	}
+	return result, true
+}
+
+func unitsIn(p Player, loc Location) []Unit {
+	units := []Unit{}
+	for _, unit := range p.Units {
+		if unit.Location == loc {
+			units = append(units, unit)
+		}
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].ID < units[j].ID })
+	return units
+}
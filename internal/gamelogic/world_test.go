@@ -0,0 +1,105 @@
+package gamelogic
+
+import (
+	"testing"
+)
+
+func skirmish(t *testing.T) *Scenario {
+	t.Helper()
+	s, err := LoadScenario("skirmish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func spawn(t *testing.T, w *World, username string, id int, rank UnitRank, loc Location) {
+	t.Helper()
+	err := w.Spawn(SpawnedUnit{Username: username, Unit: Unit{ID: id, Rank: rank, Location: loc}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorldRejectedSpawnAddsNoPlayer(t *testing.T) {
+	w := NewWorld(skirmish(t))
+	spawn(t, w, "alice", 1, RankInfantry, "north")
+
+	err := w.Spawn(SpawnedUnit{Username: "mallory", Unit: Unit{ID: 0, Rank: RankInfantry, Location: "north"}})
+	if err == nil {
+		t.Fatal("spawn of unit 0 was accepted")
+	}
+	// a player that never spawned anything must not count as beaten
+	if winner, reason, ok := w.Victor(); ok {
+		t.Errorf("%s won (%s) against a player whose only spawn was rejected", winner, reason)
+	}
+}
+
+func TestWorldFightsAtDestination(t *testing.T) {
+	for range 20 {
+		w := NewWorld(skirmish(t))
+		spawn(t, w, "alice", 1, RankCavalry, "north")
+		spawn(t, w, "alice", 2, RankInfantry, "east")
+		spawn(t, w, "bob", 1, RankInfantry, "west")
+		spawn(t, w, "bob", 2, RankArtillery, "east")
+
+		// alice and bob share east, but the move only starts a war in west
+		results, err := w.Move(ArmyMove{
+			Player:     Player{Username: "alice"},
+			Units:      []Unit{{ID: 1, Rank: RankCavalry}},
+			ToLocation: "west",
+			Path:       []Location{"north", "west"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("got %v wars, want 1", len(results))
+		}
+		r := results[0]
+		if r.Location != "west" || r.Attacker != "alice" || r.Defender != "bob" || r.Winner != "alice" {
+			t.Fatalf("war = %+v, want alice beating bob in west", r)
+		}
+		if len(r.Killed["bob"]) != 1 || r.Killed["bob"][0].ID != 1 || len(r.Killed["alice"]) != 0 {
+			t.Errorf("killed = %v, want only bob's unit 1", r.Killed)
+		}
+		if units := w.Units("alice", []int{2}); len(units) != 1 {
+			t.Error("alice's unit in east died in a war fought in west")
+		}
+	}
+}
+
+func TestWorldChecksEveryStepOfThePath(t *testing.T) {
+	w := NewWorld(skirmish(t))
+	spawn(t, w, "alice", 1, RankInfantry, "north")
+
+	// each step is along an edge, but two of them cost more than a move can
+	_, err := w.Move(ArmyMove{
+		Player:     Player{Username: "alice"},
+		Units:      []Unit{{ID: 1, Rank: RankInfantry}},
+		ToLocation: "south",
+		Path:       []Location{"north", "centre", "south"},
+	})
+	if err == nil {
+		t.Fatal("two-step move was accepted with a budget of one")
+	}
+	if units := w.Units("alice", []int{1}); units[0].Location != "north" {
+		t.Errorf("rejected move left the unit in %s", units[0].Location)
+	}
+}
+
+func TestWorldSpawnIsIdempotent(t *testing.T) {
+	w := NewWorld(skirmish(t))
+	spawn(t, w, "alice", 1, RankInfantry, "north")
+	spawn(t, w, "alice", 1, RankInfantry, "north")
+
+	changed := SpawnedUnit{Username: "alice", Unit: Unit{ID: 1, Rank: RankCavalry, Location: "north"}}
+	err := w.Spawn(changed)
+	if err == nil {
+		t.Fatal("spawn of a different unit with a known id was accepted")
+	}
+	rejection := w.RejectSpawn(changed, err)
+	if rejection.LastUnitID != 1 || len(rejection.Units) != 1 || rejection.Units[0].Rank != RankInfantry {
+		t.Errorf("rejection = %+v, want last id 1 and the infantry the world has", rejection)
+	}
+}
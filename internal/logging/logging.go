@@ -0,0 +1,79 @@
+// Package logging sets up the structured logger used for diagnostics. Game
+// output meant for players is printed separately and never goes through it.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type Config struct {
+	// Level is one of debug, info, warn or error.
+	Level string `json:"level"`
+	// Format is text or json.
+	Format string `json:"format"`
+	// File is appended to; empty means stderr.
+	File string `json:"file"`
+}
+
+func (c Config) level() (slog.Level, error) {
+	var level slog.Level
+	if c.Level == "" {
+		return level, nil
+	}
+	err := level.UnmarshalText([]byte(c.Level))
+	if err != nil {
+		return level, fmt.Errorf("unknown log level %q", c.Level)
+	}
+	return level, nil
+}
+
+// Validate checks the level and format without opening anything.
+func (c Config) Validate() error {
+	_, err := c.level()
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(c.Format) {
+	case "", "text", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown log format %q", c.Format)
+}
+
+// New builds a logger from c. The returned closer releases the log file, if
+// there is one.
+func New(c Config) (*slog.Logger, io.Closer, error) {
+	err := c.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	level, _ := c.level()
+
+	var out io.WriteCloser = nopCloser{os.Stderr}
+	if c.File != "" {
+		f, err := os.OpenFile(c.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening log file: %v", err)
+		}
+		out = f
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(out, opts)
+	if strings.ToLower(c.Format) == "json" {
+		handler = slog.NewJSONHandler(out, opts)
+	}
+	return slog.New(handler), out, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
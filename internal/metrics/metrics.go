@@ -0,0 +1,362 @@
+// Package metrics keeps counters, gauges and histograms and exposes them in
+// the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets suit latencies in seconds, from 5ms to 10s.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry is a set of metrics that are written out together.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+type metric interface {
+	write(w io.Writer, name string)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+// Default is the registry used by the package-level constructors and Handler.
+var Default = NewRegistry()
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[name]; ok {
+		panic("metrics: " + name + " registered twice")
+	}
+	r.metrics[name] = m
+}
+
+// WriteText writes every metric in the Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for i, m := range metrics {
+		m.write(bw, names[i])
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry for Prometheus to scrape.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// vec holds one series per combination of label values.
+type vec[S any] struct {
+	labels []string
+	create func() *S
+
+	mu     sync.Mutex
+	series map[string]*S
+	values map[string][]string
+}
+
+func newVec[S any](labels []string, create func() *S) *vec[S] {
+	return &vec[S]{
+		labels: labels,
+		create: create,
+		series: map[string]*S{},
+		values: map[string][]string{},
+	}
+}
+
+func (v *vec[S]) with(values []string) *S {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for %d labels", len(values), len(v.labels)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = v.create()
+		v.series[key] = s
+		v.values[key] = append([]string{}, values...)
+	}
+	return s
+}
+
+// each calls fn for every series, ordered by label values.
+func (v *vec[S]) each(fn func(labels string, s *S)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	series := make([]*S, len(keys))
+	labels := make([]string, len(keys))
+	for i, key := range keys {
+		series[i] = v.series[key]
+		labels[i] = formatLabels(v.labels, v.values[key])
+	}
+	v.mu.Unlock()
+
+	for i := range series {
+		fn(labels[i], series[i])
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escape(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// helpEscaper escapes HELP text, in which quotes need no escaping.
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// withLabel adds one more label to an already formatted label set.
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + escape(value) + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, helpEscaper.Replace(help), name, kind)
+}
+
+// value is a float64 that can be updated atomically.
+type value struct {
+	bits atomic.Uint64
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := v.bits.Load()
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+		if v.bits.CompareAndSwap(old, updated) {
+			return
+		}
+	}
+}
+
+func (v *value) set(f float64) {
+	v.bits.Store(math.Float64bits(f))
+}
+
+func (v *value) get() float64 {
+	return math.Float64frombits(v.bits.Load())
+}
+
+type Counter struct {
+	v value
+}
+
+// Inc adds one.
+func (c *Counter) Inc() {
+	c.v.add(1)
+}
+
+// Add adds delta, which must not be negative.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	c.v.add(delta)
+}
+
+type CounterVec struct {
+	help string
+	vec  *vec[Counter]
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{help: help, vec: newVec(labels, func() *Counter { return &Counter{} })}
+	r.register(name, c)
+	return c
+}
+
+// NewCounter registers a counter with Default.
+func NewCounter(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// With returns the counter for the given label values, in label order.
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.vec.with(values)
+}
+
+func (c *CounterVec) write(w io.Writer, name string) {
+	writeHeader(w, name, c.help, "counter")
+	c.vec.each(func(labels string, s *Counter) {
+		fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(s.v.get()))
+	})
+}
+
+type Gauge struct {
+	v value
+}
+
+func (g *Gauge) Set(f float64) {
+	g.v.set(f)
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.v.add(delta)
+}
+
+func (g *Gauge) Inc() {
+	g.v.add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.v.add(-1)
+}
+
+type GaugeVec struct {
+	help string
+	vec  *vec[Gauge]
+}
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{help: help, vec: newVec(labels, func() *Gauge { return &Gauge{} })}
+	r.register(name, g)
+	return g
+}
+
+// NewGauge registers a gauge with Default.
+func NewGauge(name, help string, labels ...string) *GaugeVec {
+	return Default.NewGauge(name, help, labels...)
+}
+
+// With returns the gauge for the given label values, in label order.
+func (g *GaugeVec) With(values ...string) *Gauge {
+	return g.vec.with(values)
+}
+
+func (g *GaugeVec) write(w io.Writer, name string) {
+	writeHeader(w, name, g.help, "gauge")
+	g.vec.each(func(labels string, s *Gauge) {
+		fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(s.v.get()))
+	})
+}
+
+type Histogram struct {
+	upper  []float64
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    value
+}
+
+// Observe records one value.
+func (h *Histogram) Observe(f float64) {
+	i := sort.SearchFloat64s(h.upper, f)
+	if i < len(h.counts) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	h.sum.add(f)
+}
+
+type HistogramVec struct {
+	help    string
+	buckets []float64
+	vec     *vec[Histogram]
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds,
+// which must be sorted. A +Inf bucket is always added.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64{}, buckets...)
+	h := &HistogramVec{help: help, buckets: buckets}
+	h.vec = newVec(labels, func() *Histogram {
+		return &Histogram{upper: buckets, counts: make([]atomic.Uint64, len(buckets))}
+	})
+	r.register(name, h)
+	return h
+}
+
+// NewHistogram registers a histogram with Default.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// With returns the histogram for the given label values, in label order.
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.vec.with(values)
+}
+
+func (h *HistogramVec) write(w io.Writer, name string) {
+	writeHeader(w, name, h.help, "histogram")
+	h.vec.each(func(labels string, s *Histogram) {
+		// read the total first so the buckets never add up to more than it
+		count := s.count.Load()
+		var cumulative uint64
+		for i, upper := range s.upper {
+			cumulative += s.counts[i].Load()
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(labels, "le", formatFloat(upper)), min(cumulative, count))
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(labels, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatFloat(s.sum.get()))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, labels, count)
+	})
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"flag"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+
+	published := r.NewCounter("test_published_total", "Messages published,\nby exchange\\key.", "exchange", "key")
+	published.With("peril_topic", "army_moves.*").Add(3)
+	published.With("peril_direct", "pause").Inc()
+	published.With(`back\slash`, "quote\"and\nnewline").Inc()
+
+	r.NewCounter("test_reconnects_total", "Reconnects.").With().Add(2.5)
+	r.NewCounter("test_unused_total", "Never incremented.", "queue")
+
+	players := r.NewGauge("test_players", "Players in the game.")
+	players.With().Set(4)
+	players.With().Dec()
+
+	seconds := r.NewHistogram("test_duration_seconds", "Handler time.", []float64{0.1, 1}, "queue")
+	for _, f := range []float64{0.05, 0.1, 0.5, 3} {
+		seconds.With("moves").Observe(f)
+	}
+	seconds.With("spawns").Observe(0.25)
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("content type %q", got)
+	}
+
+	golden := filepath.Join("testdata", "exposition.txt")
+	if *update {
+		if err := os.WriteFile(golden, rec.Body.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Body.String(); got != string(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
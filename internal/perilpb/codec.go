@@ -0,0 +1,118 @@
+package perilpb
+
+import (
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/pubsub"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/routing"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec is pubsub.Proto extended to the gamelogic and routing message types,
+// which it converts to and from their generated counterparts.
+var Codec pubsub.Codec = codec{}
+
+func init() {
+	pubsub.RegisterCodec(Codec)
+}
+
+type codec struct{}
+
+func (codec) ContentType() string {
+	return pubsub.Proto.ContentType()
+}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case gamelogic.ArmyMove:
+		return proto.Marshal(FromArmyMove(v))
+	case gamelogic.SpawnedUnit:
+		return proto.Marshal(FromSpawnedUnit(v))
+	case gamelogic.SpawnRejection:
+		return proto.Marshal(FromSpawnRejection(v))
+	case gamelogic.WarResult:
+		return proto.Marshal(FromWarResult(v))
+	case gamelogic.MoveRejection:
+		return proto.Marshal(FromMoveRejection(v))
+	case gamelogic.ScenarioData:
+		return proto.Marshal(FromScenarioData(v))
+	case gamelogic.GameOver:
+		return proto.Marshal(FromGameOver(v))
+	case gamelogic.GameInfo:
+		return proto.Marshal(FromGameInfo(v))
+	case routing.PlayingState:
+		return proto.Marshal(FromPlayingState(v))
+	case routing.PauseStateRequest:
+		return proto.Marshal(FromPauseStateRequest(v))
+	case routing.GameRequest:
+		return proto.Marshal(FromGameRequest(v))
+	case routing.GameLog:
+		return proto.Marshal(FromGameLog(v))
+	}
+	return pubsub.Proto.Marshal(v)
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	switch v := v.(type) {
+	case *gamelogic.ArmyMove:
+		var m ArmyMove
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToGame()
+		return err
+	case *gamelogic.SpawnedUnit:
+		var m SpawnedUnit
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToGame()
+		return err
+	case *gamelogic.SpawnRejection:
+		var m SpawnRejection
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToGame()
+		return err
+	case *gamelogic.WarResult:
+		var m WarResult
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToGame()
+		return err
+	case *gamelogic.MoveRejection:
+		var m MoveRejection
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToGame()
+		return err
+	case *gamelogic.ScenarioData:
+		var m ScenarioData
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToGame()
+		return err
+	case *gamelogic.GameOver:
+		var m GameOver
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToGame()
+		return err
+	case *gamelogic.GameInfo:
+		var m GameInfo
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToGame()
+		return err
+	case *routing.PlayingState:
+		var m PlayingState
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToRouting()
+		return err
+	case *routing.PauseStateRequest:
+		var m PauseStateRequest
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToRouting()
+		return err
+	case *routing.GameRequest:
+		var m GameRequest
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToRouting()
+		return err
+	case *routing.GameLog:
+		var m GameLog
+		err := proto.Unmarshal(data, &m)
+		*v = m.ToRouting()
+		return err
+	}
+	return pubsub.Proto.Unmarshal(data, v)
+}
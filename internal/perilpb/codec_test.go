@@ -0,0 +1,64 @@
+package perilpb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/routing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	scenario, err := gamelogic.LoadScenario("skirmish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := scenario.Data()
+	data.Map.MaxMoveCost = 2
+	data.Map.Edges = append([]gamelogic.Edge{{From: "north", To: "south", Cost: 2}}, data.Map.Edges...)
+	unit := gamelogic.Unit{ID: 3, Rank: gamelogic.RankCavalry, Location: "europe"}
+	tests := []struct {
+		in  any
+		out any
+	}{
+		{gamelogic.ArmyMove{
+			Player:     gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{3: unit}},
+			Units:      []gamelogic.Unit{unit},
+			ToLocation: "asia",
+			Path:       []gamelogic.Location{"europe", "asia"},
+		}, &gamelogic.ArmyMove{}},
+		{gamelogic.SpawnedUnit{Username: "alice", Unit: unit, Restored: true}, &gamelogic.SpawnedUnit{}},
+		{gamelogic.SpawnRejection{Username: "alice", Reason: "stale", Unit: unit, LastUnitID: 7, Units: []gamelogic.Unit{unit}}, &gamelogic.SpawnRejection{}},
+		{gamelogic.WarResult{
+			Attacker:      "alice",
+			Defender:      "bob",
+			Location:      "europe",
+			AttackerPower: 5,
+			DefenderPower: 5,
+			Killed:        map[string][]gamelogic.Unit{"alice": {unit}, "bob": {{ID: 1, Rank: gamelogic.RankInfantry, Location: "europe"}}},
+		}, &gamelogic.WarResult{}},
+		{gamelogic.MoveRejection{Username: "alice", Reason: "too far", Units: []gamelogic.Unit{unit}}, &gamelogic.MoveRejection{}},
+		{data, &gamelogic.ScenarioData{}},
+		{gamelogic.GameOver{Winner: "alice", Reason: "holds 4 territories"}, &gamelogic.GameOver{}},
+		{gamelogic.GameInfo{ID: "0123456789abcdef", Scenario: scenario.Data()}, &gamelogic.GameInfo{}},
+		{routing.PlayingState{IsPaused: true}, &routing.PlayingState{}},
+		{routing.PauseStateRequest{}, &routing.PauseStateRequest{}},
+		{routing.GameRequest{}, &routing.GameRequest{}},
+		{routing.GameLog{CurrentTime: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC), Message: "hi", Username: "alice"}, &routing.GameLog{}},
+	}
+	for _, tt := range tests {
+		data, err := Codec.Marshal(tt.in)
+		if err != nil {
+			t.Errorf("marshalling %T: %v", tt.in, err)
+			continue
+		}
+		if err := Codec.Unmarshal(data, tt.out); err != nil {
+			t.Errorf("unmarshalling %T: %v", tt.in, err)
+			continue
+		}
+		if got := reflect.ValueOf(tt.out).Elem().Interface(); !reflect.DeepEqual(got, tt.in) {
+			t.Errorf("%T came back as %+v, want %+v", tt.in, got, tt.in)
+		}
+	}
+}
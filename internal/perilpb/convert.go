@@ -0,0 +1,280 @@
+package perilpb
+
+import (
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/routing"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func FromUnit(u gamelogic.Unit) *Unit {
+	return &Unit{
+		Id:       int64(u.ID),
+		Rank:     string(u.Rank),
+		Location: string(u.Location),
+	}
+}
+
+func (u *Unit) ToGame() gamelogic.Unit {
+	return gamelogic.Unit{
+		ID:       int(u.GetId()),
+		Rank:     gamelogic.UnitRank(u.GetRank()),
+		Location: gamelogic.Location(u.GetLocation()),
+	}
+}
+
+func FromPlayer(p gamelogic.Player) *Player {
+	units := make(map[int64]*Unit, len(p.Units))
+	for id, u := range p.Units {
+		units[int64(id)] = FromUnit(u)
+	}
+	return &Player{Username: p.Username, Units: units}
+}
+
+func (p *Player) ToGame() gamelogic.Player {
+	units := make(map[int]gamelogic.Unit, len(p.GetUnits()))
+	for id, u := range p.GetUnits() {
+		units[int(id)] = u.ToGame()
+	}
+	return gamelogic.Player{Username: p.GetUsername(), Units: units}
+}
+
+func FromArmyMove(m gamelogic.ArmyMove) *ArmyMove {
+	path := make([]string, 0, len(m.Path))
+	for _, loc := range m.Path {
+		path = append(path, string(loc))
+	}
+	return &ArmyMove{
+		Player:     FromPlayer(m.Player),
+		Units:      fromUnits(m.Units),
+		ToLocation: string(m.ToLocation),
+		Path:       path,
+	}
+}
+
+func (m *ArmyMove) ToGame() gamelogic.ArmyMove {
+	var path []gamelogic.Location
+	for _, loc := range m.GetPath() {
+		path = append(path, gamelogic.Location(loc))
+	}
+	return gamelogic.ArmyMove{
+		Player:     m.GetPlayer().ToGame(),
+		Units:      toUnits(m.GetUnits()),
+		ToLocation: gamelogic.Location(m.GetToLocation()),
+		Path:       path,
+	}
+}
+
+func fromUnits(units []gamelogic.Unit) []*Unit {
+	out := make([]*Unit, 0, len(units))
+	for _, u := range units {
+		out = append(out, FromUnit(u))
+	}
+	return out
+}
+
+func toUnits(units []*Unit) []gamelogic.Unit {
+	var out []gamelogic.Unit
+	for _, u := range units {
+		out = append(out, u.ToGame())
+	}
+	return out
+}
+
+func FromSpawnedUnit(su gamelogic.SpawnedUnit) *SpawnedUnit {
+	return &SpawnedUnit{Username: su.Username, Unit: FromUnit(su.Unit), Restored: su.Restored}
+}
+
+func (su *SpawnedUnit) ToGame() gamelogic.SpawnedUnit {
+	return gamelogic.SpawnedUnit{Username: su.GetUsername(), Unit: su.GetUnit().ToGame(), Restored: su.GetRestored()}
+}
+
+func FromSpawnRejection(sr gamelogic.SpawnRejection) *SpawnRejection {
+	return &SpawnRejection{
+		Username:   sr.Username,
+		Reason:     sr.Reason,
+		Unit:       FromUnit(sr.Unit),
+		Restored:   sr.Restored,
+		LastUnitId: int64(sr.LastUnitID),
+		Units:      fromUnits(sr.Units),
+	}
+}
+
+func (sr *SpawnRejection) ToGame() gamelogic.SpawnRejection {
+	return gamelogic.SpawnRejection{
+		Username:   sr.GetUsername(),
+		Reason:     sr.GetReason(),
+		Unit:       sr.GetUnit().ToGame(),
+		Restored:   sr.GetRestored(),
+		LastUnitID: int(sr.GetLastUnitId()),
+		Units:      toUnits(sr.GetUnits()),
+	}
+}
+
+func FromWarResult(wr gamelogic.WarResult) *WarResult {
+	killed := make(map[string]*Units, len(wr.Killed))
+	for username, units := range wr.Killed {
+		killed[username] = &Units{Units: fromUnits(units)}
+	}
+	return &WarResult{
+		Attacker:      wr.Attacker,
+		Defender:      wr.Defender,
+		Location:      string(wr.Location),
+		AttackerPower: int64(wr.AttackerPower),
+		DefenderPower: int64(wr.DefenderPower),
+		Winner:        wr.Winner,
+		Killed:        killed,
+	}
+}
+
+func (wr *WarResult) ToGame() gamelogic.WarResult {
+	killed := make(map[string][]gamelogic.Unit, len(wr.GetKilled()))
+	for username, units := range wr.GetKilled() {
+		killed[username] = toUnits(units.GetUnits())
+	}
+	return gamelogic.WarResult{
+		Attacker:      wr.GetAttacker(),
+		Defender:      wr.GetDefender(),
+		Location:      gamelogic.Location(wr.GetLocation()),
+		AttackerPower: int(wr.GetAttackerPower()),
+		DefenderPower: int(wr.GetDefenderPower()),
+		Winner:        wr.GetWinner(),
+		Killed:        killed,
+	}
+}
+
+func FromMoveRejection(mr gamelogic.MoveRejection) *MoveRejection {
+	return &MoveRejection{Username: mr.Username, Reason: mr.Reason, Units: fromUnits(mr.Units)}
+}
+
+func (mr *MoveRejection) ToGame() gamelogic.MoveRejection {
+	return gamelogic.MoveRejection{Username: mr.GetUsername(), Reason: mr.GetReason(), Units: toUnits(mr.GetUnits())}
+}
+
+func FromMapData(md gamelogic.MapData) *MapData {
+	edges := make([]*Edge, 0, len(md.Edges))
+	for _, e := range md.Edges {
+		edges = append(edges, &Edge{From: string(e.From), To: string(e.To), Cost: int64(e.Cost)})
+	}
+	territories := make([]string, 0, len(md.Territories))
+	for _, t := range md.Territories {
+		territories = append(territories, string(t))
+	}
+	return &MapData{Territories: territories, Edges: edges, MaxMoveCost: int64(md.MaxMoveCost)}
+}
+
+func (md *MapData) ToGame() gamelogic.MapData {
+	data := gamelogic.MapData{MaxMoveCost: int(md.GetMaxMoveCost())}
+	for _, t := range md.GetTerritories() {
+		data.Territories = append(data.Territories, gamelogic.Location(t))
+	}
+	for _, e := range md.GetEdges() {
+		data.Edges = append(data.Edges, gamelogic.Edge{
+			From: gamelogic.Location(e.GetFrom()),
+			To:   gamelogic.Location(e.GetTo()),
+			Cost: int(e.GetCost()),
+		})
+	}
+	return data
+}
+
+func FromScenarioData(sd gamelogic.ScenarioData) *ScenarioData {
+	ranks := make(map[string]int64, len(sd.Ranks))
+	for rank, power := range sd.Ranks {
+		ranks[string(rank)] = int64(power)
+	}
+	starting := make([]*StartingUnits, 0, len(sd.StartingUnits))
+	for _, su := range sd.StartingUnits {
+		starting = append(starting, &StartingUnits{Rank: string(su.Rank), Location: string(su.Location), Count: int64(su.Count)})
+	}
+	return &ScenarioData{
+		Name:          sd.Name,
+		Map:           FromMapData(sd.Map),
+		Ranks:         ranks,
+		StartingUnits: starting,
+		Victory: &VictoryRules{
+			Territories:  int64(sd.Victory.Territories),
+			LastStanding: sd.Victory.LastStanding,
+		},
+	}
+}
+
+func (sd *ScenarioData) ToGame() gamelogic.ScenarioData {
+	data := gamelogic.ScenarioData{
+		Name:  sd.GetName(),
+		Map:   sd.GetMap().ToGame(),
+		Ranks: make(map[gamelogic.UnitRank]int, len(sd.GetRanks())),
+		Victory: gamelogic.VictoryRules{
+			Territories:  int(sd.GetVictory().GetTerritories()),
+			LastStanding: sd.GetVictory().GetLastStanding(),
+		},
+	}
+	for rank, power := range sd.GetRanks() {
+		data.Ranks[gamelogic.UnitRank(rank)] = int(power)
+	}
+	for _, su := range sd.GetStartingUnits() {
+		data.StartingUnits = append(data.StartingUnits, gamelogic.StartingUnits{
+			Rank:     gamelogic.UnitRank(su.GetRank()),
+			Location: gamelogic.Location(su.GetLocation()),
+			Count:    int(su.GetCount()),
+		})
+	}
+	return data
+}
+
+func FromGameOver(g gamelogic.GameOver) *GameOver {
+	return &GameOver{Winner: g.Winner, Reason: g.Reason}
+}
+
+func (g *GameOver) ToGame() gamelogic.GameOver {
+	return gamelogic.GameOver{Winner: g.GetWinner(), Reason: g.GetReason()}
+}
+
+func FromGameInfo(g gamelogic.GameInfo) *GameInfo {
+	return &GameInfo{Id: g.ID, Scenario: FromScenarioData(g.Scenario)}
+}
+
+func (g *GameInfo) ToGame() gamelogic.GameInfo {
+	return gamelogic.GameInfo{ID: g.GetId(), Scenario: g.GetScenario().ToGame()}
+}
+
+func FromPlayingState(ps routing.PlayingState) *PlayingState {
+	return &PlayingState{IsPaused: ps.IsPaused}
+}
+
+func (ps *PlayingState) ToRouting() routing.PlayingState {
+	return routing.PlayingState{IsPaused: ps.GetIsPaused()}
+}
+
+func FromPauseStateRequest(routing.PauseStateRequest) *PauseStateRequest {
+	return &PauseStateRequest{}
+}
+
+func (*PauseStateRequest) ToRouting() routing.PauseStateRequest {
+	return routing.PauseStateRequest{}
+}
+
+func FromGameRequest(routing.GameRequest) *GameRequest {
+	return &GameRequest{}
+}
+
+func (*GameRequest) ToRouting() routing.GameRequest {
+	return routing.GameRequest{}
+}
+
+func FromGameLog(gl routing.GameLog) *GameLog {
+	return &GameLog{
+		CurrentTime: timestamppb.New(gl.CurrentTime),
+		Message:     gl.Message,
+		Username:    gl.Username,
+	}
+}
+
+func (gl *GameLog) ToRouting() routing.GameLog {
+	var t routing.GameLog
+	if gl.GetCurrentTime() != nil {
+		t.CurrentTime = gl.GetCurrentTime().AsTime()
+	}
+	t.Message = gl.GetMessage()
+	t.Username = gl.GetUsername()
+	return t
+}
@@ -0,0 +1,1347 @@
+// Wire format for every message exchanged over the Peril exchanges. Messages
+// encoded with these schemas are published with the content type
+// "application/x-protobuf".
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: peril.proto
+
+package perilpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Unit struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// One of the ranks of the scenario being played, such as "infantry".
+	Rank          string `protobuf:"bytes,2,opt,name=rank,proto3" json:"rank,omitempty"`
+	Location      string `protobuf:"bytes,3,opt,name=location,proto3" json:"location,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Unit) Reset() {
+	*x = Unit{}
+	mi := &file_peril_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Unit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Unit) ProtoMessage() {}
+
+func (x *Unit) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Unit.ProtoReflect.Descriptor instead.
+func (*Unit) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Unit) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Unit) GetRank() string {
+	if x != nil {
+		return x.Rank
+	}
+	return ""
+}
+
+func (x *Unit) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+type Player struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Username string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	// Keyed by unit ID.
+	Units         map[int64]*Unit `protobuf:"bytes,2,rep,name=units,proto3" json:"units,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Player) Reset() {
+	*x = Player{}
+	mi := &file_peril_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Player) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Player) ProtoMessage() {}
+
+func (x *Player) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Player.ProtoReflect.Descriptor instead.
+func (*Player) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Player) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Player) GetUnits() map[int64]*Unit {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+// Published to peril_topic with routing key "army_moves.<username>".
+type ArmyMove struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Player     *Player                `protobuf:"bytes,1,opt,name=player,proto3" json:"player,omitempty"`
+	Units      []*Unit                `protobuf:"bytes,2,rep,name=units,proto3" json:"units,omitempty"`
+	ToLocation string                 `protobuf:"bytes,3,opt,name=to_location,json=toLocation,proto3" json:"to_location,omitempty"`
+	// The territories travelled through, from where the units were to
+	// to_location.
+	Path          []string `protobuf:"bytes,4,rep,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ArmyMove) Reset() {
+	*x = ArmyMove{}
+	mi := &file_peril_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ArmyMove) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ArmyMove) ProtoMessage() {}
+
+func (x *ArmyMove) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ArmyMove.ProtoReflect.Descriptor instead.
+func (*ArmyMove) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ArmyMove) GetPlayer() *Player {
+	if x != nil {
+		return x.Player
+	}
+	return nil
+}
+
+func (x *ArmyMove) GetUnits() []*Unit {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+func (x *ArmyMove) GetToLocation() string {
+	if x != nil {
+		return x.ToLocation
+	}
+	return ""
+}
+
+func (x *ArmyMove) GetPath() []string {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
+// Published to peril_topic with routing key "spawns.<username>".
+type SpawnedUnit struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Username string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Unit     *Unit                  `protobuf:"bytes,2,opt,name=unit,proto3" json:"unit,omitempty"`
+	// Set when the unit is announced again from a restored snapshot.
+	Restored      bool `protobuf:"varint,3,opt,name=restored,proto3" json:"restored,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SpawnedUnit) Reset() {
+	*x = SpawnedUnit{}
+	mi := &file_peril_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SpawnedUnit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SpawnedUnit) ProtoMessage() {}
+
+func (x *SpawnedUnit) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SpawnedUnit.ProtoReflect.Descriptor instead.
+func (*SpawnedUnit) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SpawnedUnit) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *SpawnedUnit) GetUnit() *Unit {
+	if x != nil {
+		return x.Unit
+	}
+	return nil
+}
+
+func (x *SpawnedUnit) GetRestored() bool {
+	if x != nil {
+		return x.Restored
+	}
+	return false
+}
+
+// Published by the server to peril_topic with routing key
+// "spawn_rejections.<username>".
+type SpawnRejection struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Username string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Reason   string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	Unit     *Unit                  `protobuf:"bytes,3,opt,name=unit,proto3" json:"unit,omitempty"`
+	Restored bool                   `protobuf:"varint,4,opt,name=restored,proto3" json:"restored,omitempty"`
+	// The highest unit ID the server has seen from the player.
+	LastUnitId int64 `protobuf:"varint,5,opt,name=last_unit_id,json=lastUnitId,proto3" json:"last_unit_id,omitempty"`
+	// The server's copy of a unit with the same ID, if it has one alive.
+	Units         []*Unit `protobuf:"bytes,6,rep,name=units,proto3" json:"units,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SpawnRejection) Reset() {
+	*x = SpawnRejection{}
+	mi := &file_peril_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SpawnRejection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SpawnRejection) ProtoMessage() {}
+
+func (x *SpawnRejection) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SpawnRejection.ProtoReflect.Descriptor instead.
+func (*SpawnRejection) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SpawnRejection) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *SpawnRejection) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *SpawnRejection) GetUnit() *Unit {
+	if x != nil {
+		return x.Unit
+	}
+	return nil
+}
+
+func (x *SpawnRejection) GetRestored() bool {
+	if x != nil {
+		return x.Restored
+	}
+	return false
+}
+
+func (x *SpawnRejection) GetLastUnitId() int64 {
+	if x != nil {
+		return x.LastUnitId
+	}
+	return 0
+}
+
+func (x *SpawnRejection) GetUnits() []*Unit {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+type Units struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Units         []*Unit                `protobuf:"bytes,1,rep,name=units,proto3" json:"units,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Units) Reset() {
+	*x = Units{}
+	mi := &file_peril_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Units) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Units) ProtoMessage() {}
+
+func (x *Units) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Units.ProtoReflect.Descriptor instead.
+func (*Units) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Units) GetUnits() []*Unit {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+// Published by the server to peril_topic with routing key
+// "war_results.<attacker>".
+type WarResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Attacker      string                 `protobuf:"bytes,1,opt,name=attacker,proto3" json:"attacker,omitempty"`
+	Defender      string                 `protobuf:"bytes,2,opt,name=defender,proto3" json:"defender,omitempty"`
+	Location      string                 `protobuf:"bytes,3,opt,name=location,proto3" json:"location,omitempty"`
+	AttackerPower int64                  `protobuf:"varint,4,opt,name=attacker_power,json=attackerPower,proto3" json:"attacker_power,omitempty"`
+	DefenderPower int64                  `protobuf:"varint,5,opt,name=defender_power,json=defenderPower,proto3" json:"defender_power,omitempty"`
+	// Empty after a draw.
+	Winner string `protobuf:"bytes,6,opt,name=winner,proto3" json:"winner,omitempty"`
+	// The units each player lost, keyed by username.
+	Killed        map[string]*Units `protobuf:"bytes,7,rep,name=killed,proto3" json:"killed,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WarResult) Reset() {
+	*x = WarResult{}
+	mi := &file_peril_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WarResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WarResult) ProtoMessage() {}
+
+func (x *WarResult) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WarResult.ProtoReflect.Descriptor instead.
+func (*WarResult) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WarResult) GetAttacker() string {
+	if x != nil {
+		return x.Attacker
+	}
+	return ""
+}
+
+func (x *WarResult) GetDefender() string {
+	if x != nil {
+		return x.Defender
+	}
+	return ""
+}
+
+func (x *WarResult) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+func (x *WarResult) GetAttackerPower() int64 {
+	if x != nil {
+		return x.AttackerPower
+	}
+	return 0
+}
+
+func (x *WarResult) GetDefenderPower() int64 {
+	if x != nil {
+		return x.DefenderPower
+	}
+	return 0
+}
+
+func (x *WarResult) GetWinner() string {
+	if x != nil {
+		return x.Winner
+	}
+	return ""
+}
+
+func (x *WarResult) GetKilled() map[string]*Units {
+	if x != nil {
+		return x.Killed
+	}
+	return nil
+}
+
+// Published by the server to peril_topic with routing key
+// "move_rejections.<username>".
+type MoveRejection struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Username string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Reason   string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Where the server has the units of the rejected move.
+	Units         []*Unit `protobuf:"bytes,3,rep,name=units,proto3" json:"units,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MoveRejection) Reset() {
+	*x = MoveRejection{}
+	mi := &file_peril_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MoveRejection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MoveRejection) ProtoMessage() {}
+
+func (x *MoveRejection) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MoveRejection.ProtoReflect.Descriptor instead.
+func (*MoveRejection) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MoveRejection) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *MoveRejection) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *MoveRejection) GetUnits() []*Unit {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+type Edge struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	From  string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To    string                 `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	// 0 for the default cost of 1.
+	Cost          int64 `protobuf:"varint,3,opt,name=cost,proto3" json:"cost,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Edge) Reset() {
+	*x = Edge{}
+	mi := &file_peril_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Edge) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Edge) ProtoMessage() {}
+
+func (x *Edge) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Edge.ProtoReflect.Descriptor instead.
+func (*Edge) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Edge) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *Edge) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *Edge) GetCost() int64 {
+	if x != nil {
+		return x.Cost
+	}
+	return 0
+}
+
+// The territories of a scenario and the two-way edges between them.
+type MapData struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Territories []string               `protobuf:"bytes,1,rep,name=territories,proto3" json:"territories,omitempty"`
+	Edges       []*Edge                `protobuf:"bytes,2,rep,name=edges,proto3" json:"edges,omitempty"`
+	// The most a move can cost; 0 for 1.
+	MaxMoveCost   int64 `protobuf:"varint,3,opt,name=max_move_cost,json=maxMoveCost,proto3" json:"max_move_cost,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MapData) Reset() {
+	*x = MapData{}
+	mi := &file_peril_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MapData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MapData) ProtoMessage() {}
+
+func (x *MapData) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MapData.ProtoReflect.Descriptor instead.
+func (*MapData) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *MapData) GetTerritories() []string {
+	if x != nil {
+		return x.Territories
+	}
+	return nil
+}
+
+func (x *MapData) GetEdges() []*Edge {
+	if x != nil {
+		return x.Edges
+	}
+	return nil
+}
+
+func (x *MapData) GetMaxMoveCost() int64 {
+	if x != nil {
+		return x.MaxMoveCost
+	}
+	return 0
+}
+
+type StartingUnits struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Rank  string                 `protobuf:"bytes,1,opt,name=rank,proto3" json:"rank,omitempty"`
+	// Empty for the player's home territory.
+	Location      string `protobuf:"bytes,2,opt,name=location,proto3" json:"location,omitempty"`
+	Count         int64  `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartingUnits) Reset() {
+	*x = StartingUnits{}
+	mi := &file_peril_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartingUnits) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartingUnits) ProtoMessage() {}
+
+func (x *StartingUnits) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartingUnits.ProtoReflect.Descriptor instead.
+func (*StartingUnits) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *StartingUnits) GetRank() string {
+	if x != nil {
+		return x.Rank
+	}
+	return ""
+}
+
+func (x *StartingUnits) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+func (x *StartingUnits) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type VictoryRules struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Units in this many territories win the game; 0 if they do not.
+	Territories int64 `protobuf:"varint,1,opt,name=territories,proto3" json:"territories,omitempty"`
+	// Being the only player left with units wins the game.
+	LastStanding  bool `protobuf:"varint,2,opt,name=last_standing,json=lastStanding,proto3" json:"last_standing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VictoryRules) Reset() {
+	*x = VictoryRules{}
+	mi := &file_peril_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VictoryRules) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VictoryRules) ProtoMessage() {}
+
+func (x *VictoryRules) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VictoryRules.ProtoReflect.Descriptor instead.
+func (*VictoryRules) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *VictoryRules) GetTerritories() int64 {
+	if x != nil {
+		return x.Territories
+	}
+	return 0
+}
+
+func (x *VictoryRules) GetLastStanding() bool {
+	if x != nil {
+		return x.LastStanding
+	}
+	return false
+}
+
+type ScenarioData struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Map   *MapData               `protobuf:"bytes,2,opt,name=map,proto3" json:"map,omitempty"`
+	// The power in battle of every rank that can be spawned.
+	Ranks         map[string]int64 `protobuf:"bytes,3,rep,name=ranks,proto3" json:"ranks,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	StartingUnits []*StartingUnits `protobuf:"bytes,4,rep,name=starting_units,json=startingUnits,proto3" json:"starting_units,omitempty"`
+	Victory       *VictoryRules    `protobuf:"bytes,5,opt,name=victory,proto3" json:"victory,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScenarioData) Reset() {
+	*x = ScenarioData{}
+	mi := &file_peril_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScenarioData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScenarioData) ProtoMessage() {}
+
+func (x *ScenarioData) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScenarioData.ProtoReflect.Descriptor instead.
+func (*ScenarioData) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ScenarioData) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ScenarioData) GetMap() *MapData {
+	if x != nil {
+		return x.Map
+	}
+	return nil
+}
+
+func (x *ScenarioData) GetRanks() map[string]int64 {
+	if x != nil {
+		return x.Ranks
+	}
+	return nil
+}
+
+func (x *ScenarioData) GetStartingUnits() []*StartingUnits {
+	if x != nil {
+		return x.StartingUnits
+	}
+	return nil
+}
+
+func (x *ScenarioData) GetVictory() *VictoryRules {
+	if x != nil {
+		return x.Victory
+	}
+	return nil
+}
+
+// Published by the server to peril_direct with routing key "game_over".
+type GameOver struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Winner        string                 `protobuf:"bytes,1,opt,name=winner,proto3" json:"winner,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GameOver) Reset() {
+	*x = GameOver{}
+	mi := &file_peril_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GameOver) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GameOver) ProtoMessage() {}
+
+func (x *GameOver) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GameOver.ProtoReflect.Descriptor instead.
+func (*GameOver) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GameOver) GetWinner() string {
+	if x != nil {
+		return x.Winner
+	}
+	return ""
+}
+
+func (x *GameOver) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// Sent to peril_direct with routing key "rpc.game"; the server replies with a
+// GameInfo.
+type GameRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GameRequest) Reset() {
+	*x = GameRequest{}
+	mi := &file_peril_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GameRequest) ProtoMessage() {}
+
+func (x *GameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GameRequest.ProtoReflect.Descriptor instead.
+func (*GameRequest) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{14}
+}
+
+// The game the server is running, for clients to join.
+type GameInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Scenario      *ScenarioData          `protobuf:"bytes,2,opt,name=scenario,proto3" json:"scenario,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GameInfo) Reset() {
+	*x = GameInfo{}
+	mi := &file_peril_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GameInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GameInfo) ProtoMessage() {}
+
+func (x *GameInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GameInfo.ProtoReflect.Descriptor instead.
+func (*GameInfo) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GameInfo) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GameInfo) GetScenario() *ScenarioData {
+	if x != nil {
+		return x.Scenario
+	}
+	return nil
+}
+
+// Published to peril_direct with routing key "pause".
+type PlayingState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	IsPaused      bool                   `protobuf:"varint,1,opt,name=is_paused,json=isPaused,proto3" json:"is_paused,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlayingState) Reset() {
+	*x = PlayingState{}
+	mi := &file_peril_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlayingState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlayingState) ProtoMessage() {}
+
+func (x *PlayingState) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlayingState.ProtoReflect.Descriptor instead.
+func (*PlayingState) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *PlayingState) GetIsPaused() bool {
+	if x != nil {
+		return x.IsPaused
+	}
+	return false
+}
+
+// Sent to peril_direct with routing key "rpc.pause_state"; the server replies
+// with a PlayingState.
+type PauseStateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseStateRequest) Reset() {
+	*x = PauseStateRequest{}
+	mi := &file_peril_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseStateRequest) ProtoMessage() {}
+
+func (x *PauseStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseStateRequest.ProtoReflect.Descriptor instead.
+func (*PauseStateRequest) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{17}
+}
+
+// Published to peril_topic with routing key "game_logs.<username>".
+type GameLog struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CurrentTime   *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=current_time,json=currentTime,proto3" json:"current_time,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Username      string                 `protobuf:"bytes,3,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GameLog) Reset() {
+	*x = GameLog{}
+	mi := &file_peril_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GameLog) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GameLog) ProtoMessage() {}
+
+func (x *GameLog) ProtoReflect() protoreflect.Message {
+	mi := &file_peril_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GameLog.ProtoReflect.Descriptor instead.
+func (*GameLog) Descriptor() ([]byte, []int) {
+	return file_peril_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *GameLog) GetCurrentTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CurrentTime
+	}
+	return nil
+}
+
+func (x *GameLog) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *GameLog) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+var File_peril_proto protoreflect.FileDescriptor
+
+const file_peril_proto_rawDesc = "" +
+	"\n" +
+	"\vperil.proto\x12\bperil.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"F\n" +
+	"\x04Unit\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04rank\x18\x02 \x01(\tR\x04rank\x12\x1a\n" +
+	"\blocation\x18\x03 \x01(\tR\blocation\"\xa1\x01\n" +
+	"\x06Player\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x121\n" +
+	"\x05units\x18\x02 \x03(\v2\x1b.peril.v1.Player.UnitsEntryR\x05units\x1aH\n" +
+	"\n" +
+	"UnitsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12$\n" +
+	"\x05value\x18\x02 \x01(\v2\x0e.peril.v1.UnitR\x05value:\x028\x01\"\x8f\x01\n" +
+	"\bArmyMove\x12(\n" +
+	"\x06player\x18\x01 \x01(\v2\x10.peril.v1.PlayerR\x06player\x12$\n" +
+	"\x05units\x18\x02 \x03(\v2\x0e.peril.v1.UnitR\x05units\x12\x1f\n" +
+	"\vto_location\x18\x03 \x01(\tR\n" +
+	"toLocation\x12\x12\n" +
+	"\x04path\x18\x04 \x03(\tR\x04path\"i\n" +
+	"\vSpawnedUnit\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\"\n" +
+	"\x04unit\x18\x02 \x01(\v2\x0e.peril.v1.UnitR\x04unit\x12\x1a\n" +
+	"\brestored\x18\x03 \x01(\bR\brestored\"\xcc\x01\n" +
+	"\x0eSpawnRejection\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12\"\n" +
+	"\x04unit\x18\x03 \x01(\v2\x0e.peril.v1.UnitR\x04unit\x12\x1a\n" +
+	"\brestored\x18\x04 \x01(\bR\brestored\x12 \n" +
+	"\flast_unit_id\x18\x05 \x01(\x03R\n" +
+	"lastUnitId\x12$\n" +
+	"\x05units\x18\x06 \x03(\v2\x0e.peril.v1.UnitR\x05units\"-\n" +
+	"\x05Units\x12$\n" +
+	"\x05units\x18\x01 \x03(\v2\x0e.peril.v1.UnitR\x05units\"\xca\x02\n" +
+	"\tWarResult\x12\x1a\n" +
+	"\battacker\x18\x01 \x01(\tR\battacker\x12\x1a\n" +
+	"\bdefender\x18\x02 \x01(\tR\bdefender\x12\x1a\n" +
+	"\blocation\x18\x03 \x01(\tR\blocation\x12%\n" +
+	"\x0eattacker_power\x18\x04 \x01(\x03R\rattackerPower\x12%\n" +
+	"\x0edefender_power\x18\x05 \x01(\x03R\rdefenderPower\x12\x16\n" +
+	"\x06winner\x18\x06 \x01(\tR\x06winner\x127\n" +
+	"\x06killed\x18\a \x03(\v2\x1f.peril.v1.WarResult.KilledEntryR\x06killed\x1aJ\n" +
+	"\vKilledEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12%\n" +
+	"\x05value\x18\x02 \x01(\v2\x0f.peril.v1.UnitsR\x05value:\x028\x01\"i\n" +
+	"\rMoveRejection\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12$\n" +
+	"\x05units\x18\x03 \x03(\v2\x0e.peril.v1.UnitR\x05units\">\n" +
+	"\x04Edge\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to\x12\x12\n" +
+	"\x04cost\x18\x03 \x01(\x03R\x04cost\"u\n" +
+	"\aMapData\x12 \n" +
+	"\vterritories\x18\x01 \x03(\tR\vterritories\x12$\n" +
+	"\x05edges\x18\x02 \x03(\v2\x0e.peril.v1.EdgeR\x05edges\x12\"\n" +
+	"\rmax_move_cost\x18\x03 \x01(\x03R\vmaxMoveCost\"U\n" +
+	"\rStartingUnits\x12\x12\n" +
+	"\x04rank\x18\x01 \x01(\tR\x04rank\x12\x1a\n" +
+	"\blocation\x18\x02 \x01(\tR\blocation\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x03R\x05count\"U\n" +
+	"\fVictoryRules\x12 \n" +
+	"\vterritories\x18\x01 \x01(\x03R\vterritories\x12#\n" +
+	"\rlast_standing\x18\x02 \x01(\bR\flastStanding\"\xac\x02\n" +
+	"\fScenarioData\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12#\n" +
+	"\x03map\x18\x02 \x01(\v2\x11.peril.v1.MapDataR\x03map\x127\n" +
+	"\x05ranks\x18\x03 \x03(\v2!.peril.v1.ScenarioData.RanksEntryR\x05ranks\x12>\n" +
+	"\x0estarting_units\x18\x04 \x03(\v2\x17.peril.v1.StartingUnitsR\rstartingUnits\x120\n" +
+	"\avictory\x18\x05 \x01(\v2\x16.peril.v1.VictoryRulesR\avictory\x1a8\n" +
+	"\n" +
+	"RanksEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\":\n" +
+	"\bGameOver\x12\x16\n" +
+	"\x06winner\x18\x01 \x01(\tR\x06winner\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"\r\n" +
+	"\vGameRequest\"N\n" +
+	"\bGameInfo\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x122\n" +
+	"\bscenario\x18\x02 \x01(\v2\x16.peril.v1.ScenarioDataR\bscenario\"+\n" +
+	"\fPlayingState\x12\x1b\n" +
+	"\tis_paused\x18\x01 \x01(\bR\bisPaused\"\x13\n" +
+	"\x11PauseStateRequest\"~\n" +
+	"\aGameLog\x12=\n" +
+	"\fcurrent_time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\vcurrentTime\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1a\n" +
+	"\busername\x18\x03 \x01(\tR\busernameBAZ?github.com/ChernakovEgor/learn-pub-sub-starter/internal/perilpbb\x06proto3"
+
+var (
+	file_peril_proto_rawDescOnce sync.Once
+	file_peril_proto_rawDescData []byte
+)
+
+func file_peril_proto_rawDescGZIP() []byte {
+	file_peril_proto_rawDescOnce.Do(func() {
+		file_peril_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_peril_proto_rawDesc), len(file_peril_proto_rawDesc)))
+	})
+	return file_peril_proto_rawDescData
+}
+
+var file_peril_proto_msgTypes = make([]protoimpl.MessageInfo, 22)
+var file_peril_proto_goTypes = []any{
+	(*Unit)(nil),                  // 0: peril.v1.Unit
+	(*Player)(nil),                // 1: peril.v1.Player
+	(*ArmyMove)(nil),              // 2: peril.v1.ArmyMove
+	(*SpawnedUnit)(nil),           // 3: peril.v1.SpawnedUnit
+	(*SpawnRejection)(nil),        // 4: peril.v1.SpawnRejection
+	(*Units)(nil),                 // 5: peril.v1.Units
+	(*WarResult)(nil),             // 6: peril.v1.WarResult
+	(*MoveRejection)(nil),         // 7: peril.v1.MoveRejection
+	(*Edge)(nil),                  // 8: peril.v1.Edge
+	(*MapData)(nil),               // 9: peril.v1.MapData
+	(*StartingUnits)(nil),         // 10: peril.v1.StartingUnits
+	(*VictoryRules)(nil),          // 11: peril.v1.VictoryRules
+	(*ScenarioData)(nil),          // 12: peril.v1.ScenarioData
+	(*GameOver)(nil),              // 13: peril.v1.GameOver
+	(*GameRequest)(nil),           // 14: peril.v1.GameRequest
+	(*GameInfo)(nil),              // 15: peril.v1.GameInfo
+	(*PlayingState)(nil),          // 16: peril.v1.PlayingState
+	(*PauseStateRequest)(nil),     // 17: peril.v1.PauseStateRequest
+	(*GameLog)(nil),               // 18: peril.v1.GameLog
+	nil,                           // 19: peril.v1.Player.UnitsEntry
+	nil,                           // 20: peril.v1.WarResult.KilledEntry
+	nil,                           // 21: peril.v1.ScenarioData.RanksEntry
+	(*timestamppb.Timestamp)(nil), // 22: google.protobuf.Timestamp
+}
+var file_peril_proto_depIdxs = []int32{
+	19, // 0: peril.v1.Player.units:type_name -> peril.v1.Player.UnitsEntry
+	1,  // 1: peril.v1.ArmyMove.player:type_name -> peril.v1.Player
+	0,  // 2: peril.v1.ArmyMove.units:type_name -> peril.v1.Unit
+	0,  // 3: peril.v1.SpawnedUnit.unit:type_name -> peril.v1.Unit
+	0,  // 4: peril.v1.SpawnRejection.unit:type_name -> peril.v1.Unit
+	0,  // 5: peril.v1.SpawnRejection.units:type_name -> peril.v1.Unit
+	0,  // 6: peril.v1.Units.units:type_name -> peril.v1.Unit
+	20, // 7: peril.v1.WarResult.killed:type_name -> peril.v1.WarResult.KilledEntry
+	0,  // 8: peril.v1.MoveRejection.units:type_name -> peril.v1.Unit
+	8,  // 9: peril.v1.MapData.edges:type_name -> peril.v1.Edge
+	9,  // 10: peril.v1.ScenarioData.map:type_name -> peril.v1.MapData
+	21, // 11: peril.v1.ScenarioData.ranks:type_name -> peril.v1.ScenarioData.RanksEntry
+	10, // 12: peril.v1.ScenarioData.starting_units:type_name -> peril.v1.StartingUnits
+	11, // 13: peril.v1.ScenarioData.victory:type_name -> peril.v1.VictoryRules
+	12, // 14: peril.v1.GameInfo.scenario:type_name -> peril.v1.ScenarioData
+	22, // 15: peril.v1.GameLog.current_time:type_name -> google.protobuf.Timestamp
+	0,  // 16: peril.v1.Player.UnitsEntry.value:type_name -> peril.v1.Unit
+	5,  // 17: peril.v1.WarResult.KilledEntry.value:type_name -> peril.v1.Units
+	18, // [18:18] is the sub-list for method output_type
+	18, // [18:18] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
+}
+
+func init() { file_peril_proto_init() }
+func file_peril_proto_init() {
+	if File_peril_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_peril_proto_rawDesc), len(file_peril_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   22,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_peril_proto_goTypes,
+		DependencyIndexes: file_peril_proto_depIdxs,
+		MessageInfos:      file_peril_proto_msgTypes,
+	}.Build()
+	File_peril_proto = out.File
+	file_peril_proto_goTypes = nil
+	file_peril_proto_depIdxs = nil
+}
@@ -0,0 +1,9 @@
+// Package perilpb holds the protobuf schemas for Peril's messages and
+// converts between them and the gamelogic and routing types.
+//
+// Importing it registers a codec for "application/x-protobuf" that also
+// accepts the gamelogic and routing types, so existing handlers can consume
+// messages published by clients written in other languages.
+package perilpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative peril.proto
@@ -0,0 +1,74 @@
+package pubsub
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Publisher is the publishing side of a channel. *amqp.Channel satisfies it.
+type Publisher interface {
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// Subscriber is the declaring and consuming side of a channel.
+// *amqp.Channel satisfies it.
+type Subscriber interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+}
+
+// Channel is everything pubsub needs from a single broker channel.
+type Channel interface {
+	Publisher
+	Subscriber
+	Close() error
+}
+
+// Broker is a connection to a message broker that hands out channels.
+type Broker interface {
+	Channel() (Channel, error)
+	Close() error
+}
+
+// AMQPBroker is a Broker backed by a RabbitMQ connection.
+type AMQPBroker struct {
+	conn *amqp.Connection
+}
+
+func NewAMQPBroker(conn *amqp.Connection) *AMQPBroker {
+	return &AMQPBroker{conn: conn}
+}
+
+func Dial(url string) (*AMQPBroker, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return NewAMQPBroker(conn), nil
+}
+
+// DialConfig is Dial with TLS, SASL and other connection settings.
+func DialConfig(url string, config amqp.Config) (*AMQPBroker, error) {
+	conn, err := amqp.DialConfig(url, config)
+	if err != nil {
+		return nil, err
+	}
+	return NewAMQPBroker(conn), nil
+}
+
+func (b *AMQPBroker) Channel() (Channel, error) {
+	ch, err := b.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (b *AMQPBroker) Close() error {
+	return b.conn.Close()
+}
@@ -0,0 +1,98 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sync"
+)
+
+// Codec encodes messages for one content type.
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	JSON Codec = jsonCodec{}
+	Gob  Codec = gobCodec{}
+)
+
+var registry = struct {
+	sync.RWMutex
+	codecs map[string]Codec
+}{
+	codecs: map[string]Codec{
+		JSON.ContentType(): JSON,
+		Gob.ContentType():  Gob,
+	},
+}
+
+// RegisterCodec makes c available for decoding deliveries whose content type
+// matches c.ContentType(), replacing any codec already registered for it.
+func RegisterCodec(c Codec) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.codecs[c.ContentType()] = c
+}
+
+// CodecFor returns the codec registered for a content type. Parameters such
+// as "; charset=utf-8" are ignored.
+func CodecFor(contentType string) (Codec, bool) {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	c, ok := registry.codecs[contentType]
+	return c, ok
+}
+
+// decode picks the codec by the delivery's content type, falling back to
+// fallback for producers that did not set one.
+func decode[T any](contentType string, body []byte, fallback Codec) (T, error) {
+	var msg T
+	codec := fallback
+	if contentType != "" {
+		c, ok := CodecFor(contentType)
+		if !ok {
+			return msg, fmt.Errorf("no codec registered for content type %q", contentType)
+		}
+		codec = c
+	}
+	err := codec.Unmarshal(body, &msg)
+	return msg, err
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ContentType() string {
+	return "application/gob"
+}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(v)
+	return buffer.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,188 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ConfirmChannel is a Channel that supports publisher confirms and returns of
+// unroutable mandatory messages. *amqp.Channel satisfies it.
+type ConfirmChannel interface {
+	Channel
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	NotifyReturn(c chan amqp.Return) chan amqp.Return
+}
+
+// ErrNacked is returned when the broker refuses responsibility for a message.
+var ErrNacked = errors.New("message nacked by broker")
+
+// UnroutableError is returned when a published message did not match any
+// queue bound to its exchange.
+type UnroutableError struct {
+	Exchange   string
+	RoutingKey string
+	ReplyCode  uint16
+	ReplyText  string
+}
+
+func (e *UnroutableError) Error() string {
+	return fmt.Sprintf("message to %s with key %s was returned: %d %s", e.Exchange, e.RoutingKey, e.ReplyCode, e.ReplyText)
+}
+
+// ConfirmingPublisher publishes every message as mandatory on a channel in
+// confirm mode and waits for the broker's verdict, so a nil error means the
+// message reached at least one queue. Publishes are serialised: a return is
+// always attributed to the message currently in flight.
+type ConfirmingPublisher struct {
+	ch ConfirmChannel
+
+	publishMu sync.Mutex
+	seq       uint64
+
+	mu       sync.Mutex
+	pending  map[uint64]chan error
+	returned *amqp.Return
+	closed   bool
+	done     chan struct{}
+}
+
+func NewConfirmingPublisher(b Broker) (*ConfirmingPublisher, error) {
+	ch, err := b.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("could not create channel: %v", err)
+	}
+	cc, ok := ch.(ConfirmChannel)
+	if !ok {
+		ch.Close()
+		return nil, errors.New("channel does not support publisher confirms")
+	}
+
+	confirms := cc.NotifyPublish(make(chan amqp.Confirmation, 16))
+	returns := cc.NotifyReturn(make(chan amqp.Return, 16))
+	err = cc.Confirm(false)
+	if err != nil {
+		cc.Close()
+		return nil, fmt.Errorf("enabling confirm mode: %v", err)
+	}
+
+	p := &ConfirmingPublisher{
+		ch:      cc,
+		pending: map[uint64]chan error{},
+		done:    make(chan struct{}),
+	}
+	go p.dispatch(confirms, returns)
+	return p, nil
+}
+
+func (p *ConfirmingPublisher) dispatch(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	defer close(p.done)
+	for {
+		select {
+		case r, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			p.mu.Lock()
+			p.returned = &r
+			p.mu.Unlock()
+		case c, ok := <-confirms:
+			if !ok {
+				p.fail(amqp.ErrClosed)
+				return
+			}
+			// A return always precedes the confirm of the same message, but
+			// both may be ready at once; drain it first.
+			select {
+			case r, ok := <-returns:
+				if ok {
+					p.mu.Lock()
+					p.returned = &r
+					p.mu.Unlock()
+				}
+			default:
+			}
+			p.settle(c)
+		}
+	}
+}
+
+func (p *ConfirmingPublisher) settle(c amqp.Confirmation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var err error
+	if r := p.returned; r != nil {
+		err = &UnroutableError{
+			Exchange:   r.Exchange,
+			RoutingKey: r.RoutingKey,
+			ReplyCode:  r.ReplyCode,
+			ReplyText:  r.ReplyText,
+		}
+		p.returned = nil
+	} else if !c.Ack {
+		err = ErrNacked
+	}
+
+	if wait, ok := p.pending[c.DeliveryTag]; ok {
+		delete(p.pending, c.DeliveryTag)
+		wait <- err
+	}
+}
+
+func (p *ConfirmingPublisher) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for tag, wait := range p.pending {
+		delete(p.pending, tag)
+		wait <- err
+	}
+}
+
+// PublishWithContext publishes msg and blocks until the broker confirms it,
+// returns it as unroutable, or ctx is done. The mandatory flag is always set.
+func (p *ConfirmingPublisher) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	p.publishMu.Lock()
+	defer p.publishMu.Unlock()
+
+	wait := make(chan error, 1)
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	tag := p.seq + 1
+	p.pending[tag] = wait
+	p.mu.Unlock()
+
+	err := p.ch.PublishWithContext(ctx, exchange, key, true, immediate, msg)
+	if err != nil {
+		p.mu.Lock()
+		delete(p.pending, tag)
+		p.mu.Unlock()
+		return err
+	}
+	p.seq = tag
+
+	select {
+	case err := <-wait:
+		return err
+	case <-ctx.Done():
+		p.mu.Lock()
+		delete(p.pending, tag)
+		p.mu.Unlock()
+		return fmt.Errorf("waiting for confirm: %w", ctx.Err())
+	}
+}
+
+func (p *ConfirmingPublisher) Close() error {
+	err := p.ch.Close()
+	<-p.done
+	return err
+}
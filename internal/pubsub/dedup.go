@@ -0,0 +1,270 @@
+package pubsub
+
+import (
+	"bufio"
+	"container/list"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupStore remembers which messages a consumer has already processed.
+type DedupStore interface {
+	Seen(id string) (bool, error)
+	Add(id string) error
+}
+
+// WithDedup adds Dedup(store) to the subscription's middleware.
+func WithDedup(store DedupStore) SubscribeOption {
+	return WithMiddleware(Dedup(store))
+}
+
+// Dedup acks messages whose ID is already in store without running the
+// handler. An ID is recorded once the handler acks or discards
+// the message; messages it asks to requeue or retry are processed again, so
+// a handler that has side effects before asking for a redelivery must still
+// guard them itself. Messages without an ID are never deduplicated.
+func Dedup(store DedupStore) Middleware {
+	return func(next Handler) Handler {
+		return func(msg Message) Acktype {
+			if msg.MessageID == "" {
+				return next(msg)
+			}
+			key := msg.Queue + "/" + msg.MessageID
+			seen, err := store.Seen(key)
+			if err != nil {
+				logger().Warn("checking dedup store, handling message anyway", "queue", msg.Queue, "err", err)
+			}
+			if seen {
+				logger().Debug("acking duplicate message", "queue", msg.Queue, "message_id", msg.MessageID)
+				return Ack
+			}
+
+			ack := next(msg)
+			if ack == Ack || ack == NackDiscard {
+				err := store.Add(key)
+				if err != nil {
+					logger().Warn("recording message in dedup store", "queue", msg.Queue, "err", err)
+				}
+			}
+			return ack
+		}
+	}
+}
+
+// MemoryDedupStore keeps the most recently processed IDs in memory, evicting
+// the least recently added once it holds capacity IDs and any older than ttl.
+type MemoryDedupStore struct {
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type dedupEntry struct {
+	id    string
+	added time.Time
+}
+
+func NewMemoryDedupStore(capacity int, ttl time.Duration) *MemoryDedupStore {
+	return &MemoryDedupStore{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+func (s *MemoryDedupStore) Seen(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	_, ok := s.entries[id]
+	return ok, nil
+}
+
+func (s *MemoryDedupStore) Add(id string) error {
+	s.add(id, s.now())
+	return nil
+}
+
+func (s *MemoryDedupStore) add(id string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[id]; ok {
+		s.order.Remove(el)
+	}
+	s.entries[id] = s.order.PushBack(dedupEntry{id: id, added: at})
+	for s.capacity > 0 && s.order.Len() > s.capacity {
+		s.remove(s.order.Front())
+	}
+	s.expire()
+}
+
+// expire drops entries older than the TTL. They are kept in the order they
+// were added, so only the front of the list needs checking.
+func (s *MemoryDedupStore) expire() {
+	if s.ttl <= 0 {
+		return
+	}
+	cutoff := s.now().Add(-s.ttl)
+	for el := s.order.Front(); el != nil; el = s.order.Front() {
+		if el.Value.(dedupEntry).added.After(cutoff) {
+			return
+		}
+		s.remove(el)
+	}
+}
+
+func (s *MemoryDedupStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(dedupEntry).id)
+}
+
+func (s *MemoryDedupStore) snapshot() []dedupEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	entries := make([]dedupEntry, 0, s.order.Len())
+	for el := s.order.Front(); el != nil; el = el.Next() {
+		entries = append(entries, el.Value.(dedupEntry))
+	}
+	return entries
+}
+
+// FileDedupStore is a MemoryDedupStore that survives restarts. Every ID is
+// appended to a file, which is read back on open and rewritten with only the
+// live IDs once it has grown to twice the capacity.
+type FileDedupStore struct {
+	*MemoryDedupStore
+	path string
+
+	mu    sync.Mutex
+	file  *os.File
+	lines int
+}
+
+func NewFileDedupStore(path string, capacity int, ttl time.Duration) (*FileDedupStore, error) {
+	s := &FileDedupStore{
+		MemoryDedupStore: NewMemoryDedupStore(capacity, ttl),
+		path:             path,
+	}
+	err := s.load()
+	if err != nil {
+		return nil, fmt.Errorf("loading dedup store: %v", err)
+	}
+	err = s.compact()
+	if err != nil {
+		return nil, fmt.Errorf("compacting dedup store: %v", err)
+	}
+	return s, nil
+}
+
+func (s *FileDedupStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		nanos, id, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			// a torn write from a crash; skip it
+			continue
+		}
+		n, err := strconv.ParseInt(nanos, 10, 64)
+		if err != nil {
+			continue
+		}
+		s.MemoryDedupStore.add(id, time.Unix(0, n))
+	}
+	return scanner.Err()
+}
+
+func (s *FileDedupStore) Add(id string) error {
+	at := s.now()
+	s.MemoryDedupStore.add(id, at)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	_, err := fmt.Fprintf(s.file, "%d %s\n", at.UnixNano(), id)
+	if err != nil {
+		return err
+	}
+	s.lines++
+	if s.capacity > 0 && s.lines >= 2*s.capacity {
+		return s.compactLocked()
+	}
+	return nil
+}
+
+func (s *FileDedupStore) compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compactLocked()
+}
+
+// compactLocked writes the live IDs to a temporary file and renames it over
+// the store, so a crash leaves either the old or the new file in place.
+func (s *FileDedupStore) compactLocked() error {
+	entries := s.snapshot()
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d %s\n", e.added.UnixNano(), e.id)
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		s.file = nil
+		return err
+	}
+	s.lines = len(entries)
+	return nil
+}
+
+func (s *FileDedupStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
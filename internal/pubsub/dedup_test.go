@@ -0,0 +1,148 @@
+package pubsub
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for the dedup stores.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func checkSeen(t *testing.T, s DedupStore, id string, want bool) {
+	t.Helper()
+	seen, err := s.Seen(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen != want {
+		t.Errorf("Seen(%q) = %v, want %v", id, seen, want)
+	}
+}
+
+func add(t *testing.T, s DedupStore, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if err := s.Add(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemoryDedupStoreExpires(t *testing.T) {
+	c := &clock{t: time.Unix(1_000_000, 0)}
+	s := NewMemoryDedupStore(0, time.Minute)
+	s.now = c.now
+
+	add(t, s, "a")
+	c.t = c.t.Add(30 * time.Second)
+	add(t, s, "b")
+	checkSeen(t, s, "a", true)
+
+	c.t = c.t.Add(31 * time.Second)
+	checkSeen(t, s, "a", false)
+	checkSeen(t, s, "b", true)
+
+	c.t = c.t.Add(30 * time.Second)
+	checkSeen(t, s, "b", false)
+}
+
+func TestMemoryDedupStoreCapacity(t *testing.T) {
+	s := NewMemoryDedupStore(3, 0)
+	add(t, s, "a", "b", "c", "d")
+	checkSeen(t, s, "a", false)
+	for _, id := range []string{"b", "c", "d"} {
+		checkSeen(t, s, id, true)
+	}
+
+	// adding b again makes it the newest, so c goes next
+	add(t, s, "b", "e")
+	checkSeen(t, s, "b", true)
+	checkSeen(t, s, "c", false)
+	checkSeen(t, s, "e", true)
+}
+
+func fileLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestFileDedupStoreReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup")
+	s, err := NewFileDedupStore(path, 100, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &clock{t: time.Now().Add(-2 * time.Hour)}
+	s.now = c.now
+	add(t, s, "expired")
+	c.t = time.Now()
+	add(t, s, "a", "b")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a write torn by a crash is skipped
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("12345")
+	f.Close()
+
+	s, err = NewFileDedupStore(path, 100, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	checkSeen(t, s, "a", true)
+	checkSeen(t, s, "b", true)
+	checkSeen(t, s, "expired", false)
+	if lines := fileLines(t, path); len(lines) != 2 {
+		t.Errorf("reopening left %d lines, want the 2 live ids: %v", len(lines), lines)
+	}
+
+	add(t, s, "c")
+	checkSeen(t, s, "c", true)
+}
+
+func TestFileDedupStoreCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup")
+	s, err := NewFileDedupStore(path, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	add(t, s, "a", "b", "c")
+	if lines := fileLines(t, path); len(lines) != 3 {
+		t.Fatalf("got %d lines before reaching twice the capacity, want 3", len(lines))
+	}
+	add(t, s, "d")
+	lines := fileLines(t, path)
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], " c") || !strings.HasSuffix(lines[1], " d") {
+		t.Errorf("compacted to %v, want c and d", lines)
+	}
+
+	add(t, s, "e")
+	if lines := fileLines(t, path); len(lines) != 3 {
+		t.Errorf("got %d lines after compacting and adding one, want 3", len(lines))
+	}
+	checkSeen(t, s, "c", false)
+	checkSeen(t, s, "e", true)
+}
@@ -0,0 +1,155 @@
+package pubsub
+
+import (
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// SchemaVersionHeader carries the version of the message schema the body was
+// encoded with. Messages published before it existed have no header and are
+// reported as version 0.
+const SchemaVersionHeader = "x-schema-version"
+
+// TraceIDHeader groups every message caused by the same action. A publish
+// starts a new trace unless WithTraceID continues an existing one.
+const TraceIDHeader = "x-trace-id"
+
+// SchemaVersion is stamped on everything published through Publish.
+const SchemaVersion = 1
+
+// Metadata is the envelope a message was delivered in.
+type Metadata struct {
+	MessageID string
+	Timestamp time.Time
+	// Sender is who published the message. If the publisher set a user-id,
+	// RabbitMQ has checked it against the user the connection logged in as,
+	// and SenderVerified is set. Otherwise Sender is the AppId, which the
+	// publisher can set to anything and is only advisory.
+	Sender         string
+	SenderVerified bool
+	// CorrelationID is the ID of the message this one was published in
+	// response to, or the message's own ID if it starts a conversation.
+	CorrelationID string
+	TraceID       string
+	ReplyTo       string
+	SchemaVersion int
+	ContentType   string
+	Exchange      string
+	// RoutingKey is the key the message was first published with, even if
+	// it has since been through a retry queue.
+	RoutingKey  string
+	Redelivered bool
+	// Retries is how many times the message has been through a retry queue
+	// after its handler returned NackRetryLater.
+	Retries int
+	Headers amqp.Table
+}
+
+func metadataOf(d amqp.Delivery) Metadata {
+	exchange := d.Exchange
+	if ex, ok := d.Headers[originalExchangeHeader].(string); ok {
+		exchange = ex
+	}
+	traceID, _ := d.Headers[TraceIDHeader].(string)
+	sender, verified := d.AppId, false
+	if d.UserId != "" {
+		sender, verified = d.UserId, true
+	}
+	return Metadata{
+		MessageID:      d.MessageId,
+		Timestamp:      d.Timestamp,
+		Sender:         sender,
+		SenderVerified: verified,
+		CorrelationID:  d.CorrelationId,
+		TraceID:        traceID,
+		ReplyTo:        d.ReplyTo,
+		SchemaVersion:  intHeader(d.Headers, SchemaVersionHeader),
+		ContentType:    d.ContentType,
+		Exchange:       exchange,
+		RoutingKey:     OriginalRoutingKey(d),
+		Redelivered:    d.Redelivered,
+		Retries:        retryCount(d),
+		Headers:        d.Headers,
+	}
+}
+
+// PublishOption adjusts the envelope of a single publish.
+type PublishOption func(*amqp.Publishing)
+
+// WithSender names the publisher in the AppId property. Nothing checks it;
+// use WithUserID as well when the broker login is the sender's own.
+func WithSender(sender string) PublishOption {
+	return func(p *amqp.Publishing) {
+		p.AppId = sender
+	}
+}
+
+// WithUserID sets the user-id property, which RabbitMQ refuses unless it is
+// the user the connection logged in as.
+func WithUserID(user string) PublishOption {
+	return func(p *amqp.Publishing) {
+		p.UserId = user
+	}
+}
+
+// WithCorrelationID ties a message to the one that caused it.
+func WithCorrelationID(id string) PublishOption {
+	return func(p *amqp.Publishing) {
+		p.CorrelationId = id
+	}
+}
+
+// WithReplyTo names the queue a reply should be sent to.
+func WithReplyTo(queue string) PublishOption {
+	return func(p *amqp.Publishing) {
+		p.ReplyTo = queue
+	}
+}
+
+// WithTraceID continues the trace of the message being handled.
+func WithTraceID(id string) PublishOption {
+	return func(p *amqp.Publishing) {
+		if id != "" {
+			p.Headers[TraceIDHeader] = id
+		}
+	}
+}
+
+// WithHeader sets a custom header.
+func WithHeader(key string, value any) PublishOption {
+	return func(p *amqp.Publishing) {
+		p.Headers[key] = value
+	}
+}
+
+func envelope(contentType string, body []byte, opts []PublishOption) amqp.Publishing {
+	id := newID()
+	msg := amqp.Publishing{
+		Headers: amqp.Table{
+			SchemaVersionHeader: int64(SchemaVersion),
+			TraceIDHeader:       newID(),
+		},
+		ContentType:   contentType,
+		MessageId:     id,
+		CorrelationId: id,
+		Timestamp:     time.Now().UTC(),
+		Body:          body,
+	}
+	for _, opt := range opts {
+		opt(&msg)
+	}
+	return msg
+}
+
+func intHeader(headers amqp.Table, key string) int {
+	switch n := headers[key].(type) {
+	case int:
+		return n
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package pubsub
+
+import (
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func deliveryOf(p amqp.Publishing) amqp.Delivery {
+	return amqp.Delivery{
+		Headers:       p.Headers,
+		ContentType:   p.ContentType,
+		CorrelationId: p.CorrelationId,
+		MessageId:     p.MessageId,
+		Timestamp:     p.Timestamp,
+		UserId:        p.UserId,
+		AppId:         p.AppId,
+		Body:          p.Body,
+	}
+}
+
+func TestEnvelopeCorrelationID(t *testing.T) {
+	first := metadataOf(deliveryOf(envelope("application/json", nil, nil)))
+	if first.MessageID == "" || first.CorrelationID != first.MessageID {
+		t.Errorf("a new message has id %q and correlation id %q; want them equal", first.MessageID, first.CorrelationID)
+	}
+	if first.TraceID == "" || first.SchemaVersion != SchemaVersion || first.Timestamp.IsZero() {
+		t.Errorf("envelope is missing fields: %+v", first)
+	}
+
+	reply := metadataOf(deliveryOf(envelope("application/json", nil, []PublishOption{WithCorrelationID(first.MessageID)})))
+	if reply.CorrelationID != first.MessageID || reply.MessageID == first.MessageID {
+		t.Errorf("reply has id %q and correlation id %q; want a new id correlated to %q", reply.MessageID, reply.CorrelationID, first.MessageID)
+	}
+}
+
+func TestEnvelopeSender(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []PublishOption
+		wantSender   string
+		wantVerified bool
+	}{
+		{"anonymous", nil, "", false},
+		{"app id only", []PublishOption{WithSender("alice")}, "alice", false},
+		{"user id", []PublishOption{WithUserID("alice")}, "alice", true},
+		// the broker checked the user id, not the app id
+		{"user id wins", []PublishOption{WithSender("bob"), WithUserID("alice")}, "alice", true},
+	}
+	for _, tt := range tests {
+		meta := metadataOf(deliveryOf(envelope("application/json", nil, tt.opts)))
+		if meta.Sender != tt.wantSender || meta.SenderVerified != tt.wantVerified {
+			t.Errorf("%s: sender %q verified %v, want %q verified %v", tt.name, meta.Sender, meta.SenderVerified, tt.wantSender, tt.wantVerified)
+		}
+	}
+}
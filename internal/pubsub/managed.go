@@ -0,0 +1,792 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var consumerSerial atomic.Uint64
+
+const (
+	minReconnectDelay = 500 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+// ManagedConnection is a Broker that survives broker restarts. When the
+// underlying connection drops it redials with exponential backoff, and every
+// channel handed out through it replays the exchanges, queues, bindings and
+// QoS settings declared on it and resumes its consumers. Publishes wait for
+// the connection to come back.
+//
+// Server-named queues (declared with an empty name) get a new name on every
+// reconnect, so they should not be bound or consumed through a managed channel.
+type ManagedConnection struct {
+	dial func() (rawConnection, error)
+
+	mu       sync.Mutex
+	conn     rawConnection
+	ready    chan struct{}
+	channels []*managedChannel
+	closed   bool
+	done     chan struct{}
+}
+
+// rawConnection is the connection under a ManagedConnection, which it
+// redials when it closes.
+type rawConnection interface {
+	Channel() (rawChannel, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// rawChannel is the channel under a managedChannel. *amqp.Channel satisfies
+// it.
+type rawChannel interface {
+	ConfirmChannel
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	ExchangeDeclarePassive(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	Get(queue string, autoAck bool) (amqp.Delivery, bool, error)
+	QueuePurge(name string, noWait bool) (int, error)
+}
+
+// amqpConnection adapts *amqp.Connection to rawConnection.
+type amqpConnection struct {
+	*amqp.Connection
+}
+
+func (c amqpConnection) Channel() (rawChannel, error) {
+	ch, err := c.Connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func DialManaged(url string) (*ManagedConnection, error) {
+	return newManagedConnection(func() (rawConnection, error) {
+		conn, err := amqp.Dial(url)
+		if err != nil {
+			return nil, err
+		}
+		return amqpConnection{conn}, nil
+	})
+}
+
+// DialManagedConfig is DialManaged with TLS, SASL and other connection
+// settings, used on every redial.
+func DialManagedConfig(url string, config amqp.Config) (*ManagedConnection, error) {
+	return newManagedConnection(func() (rawConnection, error) {
+		conn, err := amqp.DialConfig(url, config)
+		if err != nil {
+			return nil, err
+		}
+		return amqpConnection{conn}, nil
+	})
+}
+
+func newManagedConnection(dial func() (rawConnection, error)) (*ManagedConnection, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	c := &ManagedConnection{
+		dial:  dial,
+		conn:  conn,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	close(c.ready)
+	go c.watch(conn)
+	return c, nil
+}
+
+func (c *ManagedConnection) watch(conn rawConnection) {
+	for {
+		err := <-conn.NotifyClose(make(chan *amqp.Error, 1))
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return
+		}
+		c.conn = nil
+		c.ready = make(chan struct{})
+		c.mu.Unlock()
+		logger().Warn("connection lost, reconnecting", "err", err)
+
+		conn = c.redial()
+		if conn == nil {
+			return
+		}
+		logger().Info("reconnected to broker")
+		reconnectsTotal.With().Inc()
+	}
+}
+
+// redial dials until it succeeds or the connection is closed, in which case
+// it returns nil.
+func (c *ManagedConnection) redial() rawConnection {
+	for attempt := 0; ; attempt++ {
+		if !sleepOrDone(backoff(attempt), c.done) {
+			return nil
+		}
+		conn, err := c.dial()
+		if err != nil {
+			logger().Warn("could not reconnect", "attempt", attempt+1, "err", err)
+			continue
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		c.conn = conn
+		close(c.ready)
+		c.mu.Unlock()
+		return conn
+	}
+}
+
+// current waits until the connection is up and returns it. It gives up with
+// amqp.ErrClosed once the connection or the caller's done channel is closed.
+func (c *ManagedConnection) current(done <-chan struct{}) (rawConnection, error) {
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return nil, amqp.ErrClosed
+		}
+		conn, ready := c.conn, c.ready
+		c.mu.Unlock()
+		if conn != nil {
+			return conn, nil
+		}
+
+		select {
+		case <-ready:
+		case <-c.done:
+			return nil, amqp.ErrClosed
+		case <-done:
+			return nil, amqp.ErrClosed
+		}
+	}
+}
+
+func (c *ManagedConnection) Channel() (Channel, error) {
+	mc := &managedChannel{
+		conn:  c,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if err := mc.reopen(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		mc.Close()
+		return nil, amqp.ErrClosed
+	}
+	c.channels = append(c.channels, mc)
+	return mc, nil
+}
+
+func (c *ManagedConnection) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	c.closed = true
+	close(c.done)
+	conn, channels := c.conn, c.channels
+	c.mu.Unlock()
+
+	for _, mc := range channels {
+		mc.Close()
+	}
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+type managedChannel struct {
+	conn *ManagedConnection
+
+	mu        sync.Mutex
+	ch        rawChannel
+	ready     chan struct{}
+	ops       []recordedOp
+	consumers []*managedConsumer
+	closed    bool
+	done      chan struct{}
+
+	// Confirms are renumbered across reconnects so that listeners see one
+	// continuous sequence of delivery tags, and messages lost with a dead
+	// channel are reported as nacks.
+	confirming       bool
+	published        uint64
+	confirmGen       *confirmGeneration
+	confirmListeners []chan amqp.Confirmation
+	returnListeners  []chan amqp.Return
+	notifyWG         sync.WaitGroup
+}
+
+// recordedOp is a declaration replayed on every new channel. Declaring the
+// same thing again replaces the earlier op rather than adding another.
+type recordedOp struct {
+	key string
+	op  func(rawChannel) error
+}
+
+type confirmGeneration struct {
+	ch        rawChannel
+	offset    uint64
+	published uint64
+}
+
+type managedConsumer struct {
+	queue     string
+	tag       string
+	autoAck   bool
+	exclusive bool
+	noLocal   bool
+	args      amqp.Table
+
+	out  chan amqp.Delivery
+	stop chan struct{}
+	wg   sync.WaitGroup
+	// stopped is guarded by the channel's mu. Once it is set no forwarder is
+	// started, so waiting on wg is final.
+	stopped bool
+}
+
+// reopen opens a fresh channel on the current connection, replays the
+// recorded declarations and restarts the consumers.
+func (mc *managedChannel) reopen() error {
+	conn, err := mc.conn.current(mc.done)
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("opening channel: %v", err)
+	}
+
+	mc.mu.Lock()
+	ops := append([]recordedOp{}, mc.ops...)
+	consumers := append([]*managedConsumer{}, mc.consumers...)
+	mc.mu.Unlock()
+
+	for _, op := range ops {
+		if err := op.op(ch); err != nil {
+			ch.Close()
+			return fmt.Errorf("restoring topology: %v", err)
+		}
+	}
+	for _, c := range consumers {
+		if err := mc.startConsumer(c, ch); err != nil {
+			ch.Close()
+			return fmt.Errorf("restoring consumer %s: %v", c.tag, err)
+		}
+	}
+	mc.attachNotifications(ch)
+
+	mc.mu.Lock()
+	if mc.closed {
+		mc.mu.Unlock()
+		ch.Close()
+		return amqp.ErrClosed
+	}
+	mc.ch = ch
+	close(mc.ready)
+	mc.mu.Unlock()
+
+	go mc.watch(ch)
+	return nil
+}
+
+func (mc *managedChannel) watch(ch rawChannel) {
+	err := <-ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	if !mc.invalidate(ch) {
+		return
+	}
+	if err != nil {
+		logger().Warn("channel closed, reopening", "err", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := mc.reopen()
+		if err == nil || errors.Is(err, amqp.ErrClosed) {
+			return
+		}
+		logger().Warn("could not reopen channel", "attempt", attempt+1, "err", err)
+		if !sleepOrDone(backoff(attempt), mc.done) {
+			return
+		}
+	}
+}
+
+// invalidate marks ch as dead so callers wait for its replacement. It
+// reports false if the managed channel has been closed.
+func (mc *managedChannel) invalidate(ch rawChannel) bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.closed {
+		return false
+	}
+	if mc.ch == ch {
+		mc.ch = nil
+		mc.ready = make(chan struct{})
+	}
+	return true
+}
+
+func (mc *managedChannel) current(ctx context.Context) (rawChannel, error) {
+	for {
+		mc.mu.Lock()
+		if mc.closed {
+			mc.mu.Unlock()
+			return nil, amqp.ErrClosed
+		}
+		ch, ready := mc.ch, mc.ready
+		mc.mu.Unlock()
+		if ch != nil {
+			return ch, nil
+		}
+
+		select {
+		case <-ready:
+		case <-mc.done:
+			return nil, amqp.ErrClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// do runs op against the live channel, retrying once the channel is back if
+// it was closed underneath us. Successful ops with a key are recorded for
+// replay, replacing any earlier op with the same key.
+func (mc *managedChannel) do(ctx context.Context, key string, op func(rawChannel) error) error {
+	for {
+		ch, err := mc.current(ctx)
+		if err != nil {
+			return err
+		}
+		err = op(ch)
+		if errors.Is(err, amqp.ErrClosed) {
+			mc.invalidate(ch)
+			continue
+		}
+		if err == nil && key != "" {
+			mc.record(key, op)
+		}
+		return err
+	}
+}
+
+func (mc *managedChannel) record(key string, op func(rawChannel) error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for i := range mc.ops {
+		if mc.ops[i].key == key {
+			mc.ops[i].op = op
+			return
+		}
+	}
+	mc.ops = append(mc.ops, recordedOp{key: key, op: op})
+}
+
+func (mc *managedChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	return mc.do(ctx, "", func(ch rawChannel) error {
+		err := ch.PublishWithContext(ctx, exchange, key, mandatory, immediate, msg)
+		if err != nil {
+			return err
+		}
+
+		mc.mu.Lock()
+		defer mc.mu.Unlock()
+		if mc.confirming && mc.confirmGen != nil && mc.confirmGen.ch == ch {
+			mc.confirmGen.published++
+			mc.published++
+		}
+		return nil
+	})
+}
+
+func (mc *managedChannel) Confirm(noWait bool) error {
+	return mc.do(context.Background(), "confirm", func(ch rawChannel) error {
+		err := ch.Confirm(noWait)
+		if err != nil {
+			return err
+		}
+		mc.mu.Lock()
+		mc.confirming = true
+		mc.mu.Unlock()
+		return nil
+	})
+}
+
+func (mc *managedChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	mc.mu.Lock()
+	if mc.closed {
+		mc.mu.Unlock()
+		close(confirm)
+		return confirm
+	}
+	mc.confirmListeners = append(mc.confirmListeners, confirm)
+	ch := mc.ch
+	mc.mu.Unlock()
+
+	if ch != nil {
+		mc.attachNotifications(ch)
+	}
+	return confirm
+}
+
+func (mc *managedChannel) NotifyReturn(c chan amqp.Return) chan amqp.Return {
+	mc.mu.Lock()
+	if mc.closed {
+		mc.mu.Unlock()
+		close(c)
+		return c
+	}
+	mc.returnListeners = append(mc.returnListeners, c)
+	ch := mc.ch
+	mc.mu.Unlock()
+
+	if ch != nil {
+		mc.attachNotifications(ch)
+	}
+	return c
+}
+
+// attachNotifications subscribes to confirms and returns on ch, once per
+// underlying channel, and forwards them to the registered listeners. Both are
+// forwarded from one goroutine so listeners see a return before the confirm
+// of the same message, as the broker sends them.
+func (mc *managedChannel) attachNotifications(ch rawChannel) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if len(mc.confirmListeners) == 0 && len(mc.returnListeners) == 0 {
+		return
+	}
+	if mc.confirmGen != nil && mc.confirmGen.ch == ch {
+		return
+	}
+	gen := &confirmGeneration{ch: ch, offset: mc.published}
+	mc.confirmGen = gen
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 16))
+	returns := ch.NotifyReturn(make(chan amqp.Return, 16))
+	mc.notifyWG.Add(1)
+	go mc.forwardNotifications(gen, confirms, returns)
+}
+
+func (mc *managedChannel) forwardNotifications(gen *confirmGeneration, confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	defer mc.notifyWG.Done()
+
+	last := gen.offset
+	for confirms != nil {
+		select {
+		case r, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			mc.emitReturn(r)
+		case c, ok := <-confirms:
+			if !ok {
+				confirms = nil
+				continue
+			}
+			// The broker sends a return before the confirm of the same
+			// message, but both may be ready at once; forward it first.
+			mc.drainReturns(returns)
+			c.DeliveryTag += gen.offset
+			last = c.DeliveryTag
+			mc.emitConfirm(c)
+		}
+	}
+	mc.drainReturns(returns)
+
+	// The channel is gone; anything it never confirmed is lost.
+	mc.mu.Lock()
+	end := gen.offset + gen.published
+	mc.mu.Unlock()
+	for tag := last + 1; tag <= end; tag++ {
+		mc.emitConfirm(amqp.Confirmation{DeliveryTag: tag, Ack: false})
+	}
+}
+
+// drainReturns forwards the returns that are already waiting in returns.
+func (mc *managedChannel) drainReturns(returns <-chan amqp.Return) {
+	for {
+		select {
+		case r, ok := <-returns:
+			if !ok {
+				return
+			}
+			mc.emitReturn(r)
+		default:
+			return
+		}
+	}
+}
+
+func (mc *managedChannel) emitConfirm(c amqp.Confirmation) {
+	mc.mu.Lock()
+	listeners := mc.confirmListeners
+	mc.mu.Unlock()
+	for _, l := range listeners {
+		l <- c
+	}
+}
+
+func (mc *managedChannel) emitReturn(r amqp.Return) {
+	mc.mu.Lock()
+	listeners := mc.returnListeners
+	mc.mu.Unlock()
+	for _, l := range listeners {
+		l <- r
+	}
+}
+
+func (mc *managedChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	return mc.do(context.Background(), "exchange "+name, func(ch rawChannel) error {
+		return ch.ExchangeDeclare(name, kind, durable, autoDelete, internal, noWait, args)
+	})
+}
+
+func (mc *managedChannel) ExchangeDeclarePassive(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	return mc.do(context.Background(), "", func(ch rawChannel) error {
+		return ch.ExchangeDeclarePassive(name, kind, durable, autoDelete, internal, noWait, args)
+	})
+}
+
+func (mc *managedChannel) QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	var queue amqp.Queue
+	err := mc.do(context.Background(), "", func(ch rawChannel) error {
+		q, err := ch.QueueDeclarePassive(name, durable, autoDelete, exclusive, noWait, args)
+		queue = q
+		return err
+	})
+	return queue, err
+}
+
+func (mc *managedChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	var queue amqp.Queue
+	err := mc.do(context.Background(), "queue "+name, func(ch rawChannel) error {
+		q, err := ch.QueueDeclare(name, durable, autoDelete, exclusive, noWait, args)
+		queue = q
+		return err
+	})
+	return queue, err
+}
+
+func (mc *managedChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	return mc.do(context.Background(), "bind "+name+" "+exchange+" "+key, func(ch rawChannel) error {
+		return ch.QueueBind(name, key, exchange, noWait, args)
+	})
+}
+
+func (mc *managedChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	return mc.do(context.Background(), fmt.Sprintf("qos %v", global), func(ch rawChannel) error {
+		return ch.Qos(prefetchCount, prefetchSize, global)
+	})
+}
+
+func (mc *managedChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	if consumer == "" {
+		consumer = fmt.Sprintf("peril-%d", consumerSerial.Add(1))
+	}
+	c := &managedConsumer{
+		queue:     queue,
+		tag:       consumer,
+		autoAck:   autoAck,
+		exclusive: exclusive,
+		noLocal:   noLocal,
+		args:      args,
+		out:       make(chan amqp.Delivery),
+		stop:      make(chan struct{}),
+	}
+
+	err := mc.do(context.Background(), "", func(ch rawChannel) error {
+		return mc.startConsumer(c, ch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.consumers = append(mc.consumers, c)
+	return c.out, nil
+}
+
+func (mc *managedChannel) Get(queue string, autoAck bool) (amqp.Delivery, bool, error) {
+	var (
+		delivery amqp.Delivery
+		ok       bool
+	)
+	err := mc.do(context.Background(), "", func(ch rawChannel) error {
+		d, found, err := ch.Get(queue, autoAck)
+		delivery, ok = d, found
+		return err
+	})
+	return delivery, ok, err
+}
+
+func (mc *managedChannel) QueuePurge(name string, noWait bool) (int, error) {
+	var n int
+	err := mc.do(context.Background(), "", func(ch rawChannel) error {
+		purged, err := ch.QueuePurge(name, noWait)
+		n = purged
+		return err
+	})
+	return n, err
+}
+
+func (mc *managedChannel) Cancel(consumer string, noWait bool) error {
+	mc.mu.Lock()
+	var c *managedConsumer
+	for i, mcon := range mc.consumers {
+		if mcon.tag == consumer {
+			c = mcon
+			c.stopped = true
+			mc.consumers = append(mc.consumers[:i:i], mc.consumers[i+1:]...)
+			break
+		}
+	}
+	ch := mc.ch
+	mc.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+
+	var err error
+	if ch != nil {
+		err = ch.Cancel(consumer, noWait)
+		if errors.Is(err, amqp.ErrClosed) {
+			err = nil
+		}
+	}
+	close(c.stop)
+	c.wg.Wait()
+	close(c.out)
+	return err
+}
+
+func (mc *managedChannel) Close() error {
+	mc.mu.Lock()
+	if mc.closed {
+		mc.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	mc.closed = true
+	close(mc.done)
+	ch, consumers := mc.ch, mc.consumers
+	for _, c := range consumers {
+		c.stopped = true
+	}
+	mc.mu.Unlock()
+
+	var err error
+	if ch != nil {
+		err = ch.Close()
+	}
+	for _, c := range consumers {
+		close(c.stop)
+		c.wg.Wait()
+		close(c.out)
+	}
+
+	mc.notifyWG.Wait()
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for _, l := range mc.confirmListeners {
+		close(l)
+	}
+	for _, l := range mc.returnListeners {
+		close(l)
+	}
+	return err
+}
+
+// startConsumer consumes from ch and forwards deliveries to the consumer's
+// stable output channel until ch goes away or the consumer is stopped. It
+// does nothing for a consumer that has already been stopped.
+func (mc *managedChannel) startConsumer(c *managedConsumer, ch rawChannel) error {
+	mc.mu.Lock()
+	if c.stopped {
+		mc.mu.Unlock()
+		return nil
+	}
+	c.wg.Add(1)
+	mc.mu.Unlock()
+
+	deliveries, err := ch.Consume(c.queue, c.tag, c.autoAck, c.exclusive, c.noLocal, false, c.args)
+	if err != nil {
+		c.wg.Done()
+		return err
+	}
+	go func() {
+		defer c.wg.Done()
+		for {
+			select {
+			case d, ok := <-deliveries:
+				if !ok {
+					return
+				}
+				select {
+				case <-c.stop:
+					return
+				default:
+				}
+				select {
+				case c.out <- d:
+				case <-c.stop:
+					return
+				}
+			case <-c.stop:
+				// Cancel may have missed this channel while it was being
+				// reopened.
+				ch.Cancel(c.tag, false)
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func backoff(attempt int) time.Duration {
+	delay := minReconnectDelay
+	for i := 0; i < attempt && delay < maxReconnectDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxReconnectDelay)
+}
+
+func sleepOrDone(d time.Duration, done <-chan struct{}) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-done:
+		return false
+	}
+}
@@ -0,0 +1,177 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// memoryRawConnection lets a ManagedConnection dial a MemoryBroker.
+type memoryRawConnection struct {
+	*MemoryConnection
+}
+
+func (c memoryRawConnection) Channel() (rawChannel, error) {
+	ch, err := c.MemoryConnection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return ch.(*memChannel), nil
+}
+
+// memoryDialer dials b and remembers every connection it made. The next fail
+// dials are refused.
+type memoryDialer struct {
+	b *MemoryBroker
+
+	mu    sync.Mutex
+	conns []*MemoryConnection
+	fail  int
+}
+
+func (d *memoryDialer) dial() (rawConnection, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fail > 0 {
+		d.fail--
+		return nil, errors.New("connection refused")
+	}
+	conn := d.b.Dial()
+	d.conns = append(d.conns, conn)
+	return memoryRawConnection{conn}, nil
+}
+
+func (d *memoryDialer) last() *MemoryConnection {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.conns[len(d.conns)-1]
+}
+
+func (d *memoryDialer) dials() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.conns)
+}
+
+func newManagedMemory(t *testing.T, b *MemoryBroker) (*ManagedConnection, *memoryDialer) {
+	t.Helper()
+	d := &memoryDialer{b: b}
+	conn, err := newManagedConnection(d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, d
+}
+
+func TestManagedChannelReturnsPrecedeConfirms(t *testing.T) {
+	b, ch := newMemoryChannel(t)
+	declare(t, ch, "peril_direct", "direct", "routed", "routed", nil)
+	conn, _ := newManagedMemory(t, b)
+
+	p, err := NewConfirmingPublisher(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 200; i++ {
+		err := p.PublishWithContext(ctx, "peril_direct", "nowhere", false, false, envelope("text/plain", nil, nil))
+		var unroutable *UnroutableError
+		if !errors.As(err, &unroutable) {
+			t.Fatalf("publish %d to an unbound key: got %v, want UnroutableError", i, err)
+		}
+		if err := p.PublishWithContext(ctx, "peril_direct", "routed", false, false, envelope("text/plain", nil, nil)); err != nil {
+			t.Fatalf("publish %d to a bound key: %v", i, err)
+		}
+	}
+}
+
+func TestManagedConnectionReconnects(t *testing.T) {
+	b, ch := newMemoryChannel(t)
+	if err := ch.ExchangeDeclare("peril_topic", "topic", true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	conn, d := newManagedMemory(t, b)
+
+	mch, err := conn.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An exclusive queue dies with its connection, so it only comes back if
+	// the topology is replayed.
+	if _, err := mch.QueueDeclare("moves", false, false, true, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := mch.QueueBind("moves", "army_moves.*", "peril_topic", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := mch.Consume("moves", "", false, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewConfirmingPublisher(b.Dial())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	send := func(body string) error {
+		return p.PublishWithContext(context.Background(), "peril_topic", "army_moves.alice", true, false, amqp.Publishing{Body: []byte(body)})
+	}
+	receive := func(want string) {
+		t.Helper()
+		select {
+		case d, ok := <-deliveries:
+			if !ok {
+				t.Fatal("deliveries closed")
+			}
+			if string(d.Body) != want {
+				t.Fatalf("got %q, want %q", d.Body, want)
+			}
+			if err := d.Ack(false); err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+
+	if err := send("before"); err != nil {
+		t.Fatal(err)
+	}
+	receive("before")
+
+	d.mu.Lock()
+	d.fail = 1
+	d.mu.Unlock()
+	d.last().Close()
+
+	// The queue is unroutable until the channel has been reopened on the new
+	// connection and has redeclared and rebound it.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		err := send("after")
+		if err == nil {
+			break
+		}
+		var unroutable *UnroutableError
+		if !errors.As(err, &unroutable) {
+			t.Fatal(err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("topology was not restored after reconnecting")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	receive("after")
+
+	if n := d.dials(); n != 2 {
+		t.Errorf("dialled %d times, want 2", n)
+	}
+}
@@ -0,0 +1,895 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// MemoryBroker is an in-process stand-in for RabbitMQ. It supports direct,
+// topic and fanout exchanges, durable and transient queues, acks, nacks,
+// requeueing and dead-lettering, so handlers can be exercised without a
+// running server. Clients talk to it through connections returned by Dial.
+type MemoryBroker struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	exchanges map[string]*memExchange
+	queues    map[string]*memQueue
+	serial    int
+}
+
+type memExchange struct {
+	kind     string
+	durable  bool
+	bindings []memBinding
+}
+
+type memBinding struct {
+	queue string
+	key   string
+}
+
+type memQueue struct {
+	name         string
+	durable      bool
+	autoDelete   bool
+	exclusive    bool
+	args         amqp.Table
+	owner        *MemoryConnection
+	ready        []*memMessage
+	consumers    int
+	hadConsumers bool
+}
+
+type memMessage struct {
+	exchange    string
+	key         string
+	pub         amqp.Publishing
+	redelivered bool
+	expires     time.Time
+}
+
+type memConsumer struct {
+	tag       string
+	queue     *memQueue
+	autoAck   bool
+	prefetch  int
+	inflight  int
+	cancelled bool
+	done      chan struct{}
+	out       chan amqp.Delivery
+}
+
+type memUnacked struct {
+	msg      *memMessage
+	queue    *memQueue
+	consumer *memConsumer
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	b := &MemoryBroker{
+		exchanges: map[string]*memExchange{},
+		queues:    map[string]*memQueue{},
+	}
+	b.cond = sync.NewCond(&b.mu)
+	for name, kind := range map[string]string{
+		"amq.direct": amqp.ExchangeDirect,
+		"amq.topic":  amqp.ExchangeTopic,
+		"amq.fanout": amqp.ExchangeFanout,
+	} {
+		b.exchanges[name] = &memExchange{kind: kind, durable: true}
+	}
+	return b
+}
+
+// Dial opens a new connection to the broker. Exclusive queues declared
+// through it are deleted when it is closed.
+func (b *MemoryBroker) Dial() *MemoryConnection {
+	return &MemoryConnection{broker: b}
+}
+
+// QueueLength reports the number of ready (undelivered) messages in a queue.
+func (b *MemoryBroker) QueueLength(name string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		return 0
+	}
+	return len(q.ready)
+}
+
+func (b *MemoryBroker) nextName(prefix string) string {
+	b.serial++
+	return fmt.Sprintf("%s%d", prefix, b.serial)
+}
+
+func (b *MemoryBroker) route(exchange, key string, pub amqp.Publishing) (int, error) {
+	if exchange == "" {
+		q, ok := b.queues[key]
+		if !ok {
+			return 0, nil
+		}
+		b.enqueue(q, &memMessage{exchange: exchange, key: key, pub: copyPublishing(pub)})
+		return 1, nil
+	}
+
+	ex, ok := b.exchanges[exchange]
+	if !ok {
+		return 0, &amqp.Error{Code: amqp.NotFound, Reason: fmt.Sprintf("NOT_FOUND - no exchange '%s'", exchange)}
+	}
+
+	routed := map[string]bool{}
+	for _, bd := range ex.bindings {
+		if routed[bd.queue] || !ex.matches(bd.key, key) {
+			continue
+		}
+		q, ok := b.queues[bd.queue]
+		if !ok {
+			continue
+		}
+		routed[bd.queue] = true
+		b.enqueue(q, &memMessage{exchange: exchange, key: key, pub: copyPublishing(pub)})
+	}
+	return len(routed), nil
+}
+
+func (b *MemoryBroker) enqueue(q *memQueue, m *memMessage) {
+	if ttl, ok := messageTTL(q, m); ok {
+		m.expires = time.Now().Add(ttl)
+		time.AfterFunc(ttl, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.expire(q)
+		})
+	}
+	q.ready = append(q.ready, m)
+	b.cond.Broadcast()
+}
+
+// expire dead-letters expired messages from the head of the queue. Like
+// RabbitMQ, only the head is checked, which is exact for per-queue TTLs.
+func (b *MemoryBroker) expire(q *memQueue) {
+	if _, ok := b.queues[q.name]; !ok {
+		return
+	}
+	now := time.Now()
+	for len(q.ready) > 0 && !q.ready[0].expires.IsZero() && !q.ready[0].expires.After(now) {
+		m := q.ready[0]
+		q.ready = q.ready[1:]
+		b.deadLetter(q, m, "expired")
+	}
+}
+
+// messageTTL is the lower of the queue's x-message-ttl and the message's
+// own expiration, if either is set.
+func messageTTL(q *memQueue, m *memMessage) (time.Duration, bool) {
+	var ttl time.Duration
+	found := false
+	switch v := q.args["x-message-ttl"].(type) {
+	case int:
+		ttl, found = time.Duration(v)*time.Millisecond, true
+	case int32:
+		ttl, found = time.Duration(v)*time.Millisecond, true
+	case int64:
+		ttl, found = time.Duration(v)*time.Millisecond, true
+	}
+	if ms, err := strconv.ParseInt(m.pub.Expiration, 10, 64); err == nil {
+		if exp := time.Duration(ms) * time.Millisecond; !found || exp < ttl {
+			ttl, found = exp, true
+		}
+	}
+	return ttl, found
+}
+
+func (b *MemoryBroker) requeue(q *memQueue, m *memMessage) {
+	if _, ok := b.queues[q.name]; !ok {
+		return
+	}
+	m.redelivered = true
+	q.ready = append([]*memMessage{m}, q.ready...)
+	b.cond.Broadcast()
+}
+
+func (b *MemoryBroker) deadLetter(q *memQueue, m *memMessage, reason string) {
+	dlx, ok := q.args["x-dead-letter-exchange"].(string)
+	if !ok {
+		return
+	}
+	key := m.key
+	if k, ok := q.args["x-dead-letter-routing-key"].(string); ok {
+		key = k
+	}
+
+	pub := copyPublishing(m.pub)
+	pub.Headers["x-death"] = addDeath(pub.Headers["x-death"], q.name, reason, m.exchange, m.key)
+	if _, ok := pub.Headers["x-first-death-queue"]; !ok {
+		pub.Headers["x-first-death-queue"] = q.name
+		pub.Headers["x-first-death-reason"] = reason
+		pub.Headers["x-first-death-exchange"] = m.exchange
+	}
+	b.route(dlx, key, pub)
+}
+
+func (b *MemoryBroker) deleteQueue(q *memQueue) {
+	delete(b.queues, q.name)
+	for _, ex := range b.exchanges {
+		kept := ex.bindings[:0]
+		for _, bd := range ex.bindings {
+			if bd.queue != q.name {
+				kept = append(kept, bd)
+			}
+		}
+		ex.bindings = kept
+	}
+	b.cond.Broadcast()
+}
+
+func addDeath(existing any, queue, reason, exchange, key string) []any {
+	deaths, _ := existing.([]any)
+	for i, d := range deaths {
+		t, ok := d.(amqp.Table)
+		if !ok || t["queue"] != queue || t["reason"] != reason {
+			continue
+		}
+		count, _ := t["count"].(int64)
+		updated := amqp.Table{}
+		for k, v := range t {
+			updated[k] = v
+		}
+		updated["count"] = count + 1
+		updated["time"] = time.Now()
+		rest := append([]any{}, deaths[:i]...)
+		rest = append(rest, deaths[i+1:]...)
+		return append([]any{updated}, rest...)
+	}
+	death := amqp.Table{
+		"count":        int64(1),
+		"reason":       reason,
+		"queue":        queue,
+		"time":         time.Now(),
+		"exchange":     exchange,
+		"routing-keys": []any{key},
+	}
+	return append([]any{death}, deaths...)
+}
+
+func copyPublishing(pub amqp.Publishing) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range pub.Headers {
+		headers[k] = v
+	}
+	pub.Headers = headers
+	return pub
+}
+
+func (ex *memExchange) matches(binding, key string) bool {
+	switch ex.kind {
+	case amqp.ExchangeFanout:
+		return true
+	case amqp.ExchangeTopic:
+		return topicMatch(strings.Split(binding, "."), strings.Split(key, "."))
+	default:
+		return binding == key
+	}
+}
+
+// topicMatch reports whether a routing key matches a topic binding pattern,
+// where "*" matches exactly one word and "#" matches zero or more words.
+func topicMatch(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if topicMatch(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && topicMatch(pattern[1:], words[1:])
+	}
+	return len(words) > 0 && pattern[0] == words[0] && topicMatch(pattern[1:], words[1:])
+}
+
+// MemoryConnection is a Broker connected to a MemoryBroker.
+type MemoryConnection struct {
+	broker         *MemoryBroker
+	channels       []*memChannel
+	closeListeners []chan *amqp.Error
+	closed         bool
+}
+
+func (c *MemoryConnection) Channel() (Channel, error) {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c.closed {
+		return nil, amqp.ErrClosed
+	}
+	ch := &memChannel{
+		conn:      c,
+		unacked:   map[uint64]*memUnacked{},
+		consumers: map[string]*memConsumer{},
+	}
+	c.channels = append(c.channels, ch)
+	return ch, nil
+}
+
+func (c *MemoryConnection) Close() error {
+	b := c.broker
+	b.mu.Lock()
+	if c.closed {
+		b.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	c.closed = true
+	channels := c.channels
+	for _, l := range c.closeListeners {
+		close(l)
+	}
+	b.mu.Unlock()
+
+	for _, ch := range channels {
+		ch.Close()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, q := range b.queues {
+		if q.exclusive && q.owner == c {
+			b.deleteQueue(q)
+		}
+	}
+	return nil
+}
+
+// NotifyClose registers a listener that is closed when the connection closes,
+// like a graceful close of an AMQP connection.
+func (c *MemoryConnection) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c.closed {
+		close(receiver)
+		return receiver
+	}
+	c.closeListeners = append(c.closeListeners, receiver)
+	return receiver
+}
+
+type memChannel struct {
+	conn      *MemoryConnection
+	prefetch  int
+	nextTag   uint64
+	unacked   map[uint64]*memUnacked
+	consumers map[string]*memConsumer
+	closed    bool
+
+	// notifyMu orders publishes with their confirms and returns, and guards
+	// the listener channels. It is never taken while holding the broker lock.
+	notifyMu         sync.Mutex
+	confirming       bool
+	publishSeq       uint64
+	confirmListeners []chan amqp.Confirmation
+	returnListeners  []chan amqp.Return
+	closeListeners   []chan *amqp.Error
+	notifyClosed     bool
+}
+
+func (ch *memChannel) broker() *MemoryBroker {
+	return ch.conn.broker
+}
+
+func (ch *memChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	ch.notifyMu.Lock()
+	defer ch.notifyMu.Unlock()
+
+	b := ch.broker()
+	b.mu.Lock()
+	if ch.closed {
+		b.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	routed, err := b.route(exchange, key, msg)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if ch.notifyClosed {
+		return nil
+	}
+	if mandatory && routed == 0 {
+		r := amqp.Return{
+			ReplyCode:     amqp.NoRoute,
+			ReplyText:     "NO_ROUTE",
+			Exchange:      exchange,
+			RoutingKey:    key,
+			ContentType:   msg.ContentType,
+			Headers:       msg.Headers,
+			CorrelationId: msg.CorrelationId,
+			MessageId:     msg.MessageId,
+			Body:          msg.Body,
+		}
+		for _, l := range ch.returnListeners {
+			l <- r
+		}
+	}
+	if ch.confirming {
+		ch.publishSeq++
+		for _, l := range ch.confirmListeners {
+			l <- amqp.Confirmation{DeliveryTag: ch.publishSeq, Ack: true}
+		}
+	}
+	return nil
+}
+
+func (ch *memChannel) Confirm(noWait bool) error {
+	ch.notifyMu.Lock()
+	defer ch.notifyMu.Unlock()
+	if ch.notifyClosed {
+		return amqp.ErrClosed
+	}
+	ch.confirming = true
+	return nil
+}
+
+func (ch *memChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	ch.notifyMu.Lock()
+	defer ch.notifyMu.Unlock()
+	if ch.notifyClosed {
+		close(confirm)
+		return confirm
+	}
+	ch.confirmListeners = append(ch.confirmListeners, confirm)
+	return confirm
+}
+
+func (ch *memChannel) NotifyReturn(c chan amqp.Return) chan amqp.Return {
+	ch.notifyMu.Lock()
+	defer ch.notifyMu.Unlock()
+	if ch.notifyClosed {
+		close(c)
+		return c
+	}
+	ch.returnListeners = append(ch.returnListeners, c)
+	return c
+}
+
+// NotifyClose registers a listener that is closed when the channel closes.
+func (ch *memChannel) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	ch.notifyMu.Lock()
+	defer ch.notifyMu.Unlock()
+	if ch.notifyClosed {
+		close(receiver)
+		return receiver
+	}
+	ch.closeListeners = append(ch.closeListeners, receiver)
+	return receiver
+}
+
+func (ch *memChannel) closeNotify() {
+	ch.notifyMu.Lock()
+	defer ch.notifyMu.Unlock()
+	if ch.notifyClosed {
+		return
+	}
+	ch.notifyClosed = true
+	for _, l := range ch.confirmListeners {
+		close(l)
+	}
+	for _, l := range ch.returnListeners {
+		close(l)
+	}
+	for _, l := range ch.closeListeners {
+		close(l)
+	}
+}
+
+func (ch *memChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	switch kind {
+	case amqp.ExchangeDirect, amqp.ExchangeTopic, amqp.ExchangeFanout:
+	default:
+		return fmt.Errorf("memory broker: unsupported exchange kind %q", kind)
+	}
+
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	if ex, ok := b.exchanges[name]; ok {
+		if ex.kind != kind || ex.durable != durable {
+			return &amqp.Error{Code: amqp.PreconditionFailed, Reason: fmt.Sprintf("PRECONDITION_FAILED - inequivalent arg for exchange '%s'", name)}
+		}
+		return nil
+	}
+	b.exchanges[name] = &memExchange{kind: kind, durable: durable}
+	return nil
+}
+
+func (ch *memChannel) ExchangeDeclarePassive(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	if _, ok := b.exchanges[name]; !ok {
+		return &amqp.Error{Code: amqp.NotFound, Reason: fmt.Sprintf("NOT_FOUND - no exchange '%s'", name)}
+	}
+	return nil
+}
+
+func (ch *memChannel) QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.Queue{}, amqp.ErrClosed
+	}
+	q, ok := b.queues[name]
+	if !ok {
+		return amqp.Queue{}, &amqp.Error{Code: amqp.NotFound, Reason: fmt.Sprintf("NOT_FOUND - no queue '%s'", name)}
+	}
+	return amqp.Queue{Name: name, Messages: len(q.ready), Consumers: q.consumers}, nil
+}
+
+func (ch *memChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.Queue{}, amqp.ErrClosed
+	}
+
+	if name == "" {
+		name = b.nextName("amq.gen-")
+	}
+	if q, ok := b.queues[name]; ok {
+		if q.exclusive && q.owner != ch.conn {
+			return amqp.Queue{}, &amqp.Error{Code: amqp.ResourceLocked, Reason: fmt.Sprintf("RESOURCE_LOCKED - cannot obtain exclusive access to queue '%s'", name)}
+		}
+		if q.durable != durable {
+			return amqp.Queue{}, &amqp.Error{Code: amqp.PreconditionFailed, Reason: fmt.Sprintf("PRECONDITION_FAILED - inequivalent arg 'durable' for queue '%s'", name)}
+		}
+		for _, arg := range []string{"x-dead-letter-exchange", "x-dead-letter-routing-key", "x-message-ttl"} {
+			if fmt.Sprint(q.args[arg]) != fmt.Sprint(args[arg]) {
+				return amqp.Queue{}, &amqp.Error{Code: amqp.PreconditionFailed, Reason: fmt.Sprintf("PRECONDITION_FAILED - inequivalent arg '%s' for queue '%s'", arg, name)}
+			}
+		}
+		return amqp.Queue{Name: name, Messages: len(q.ready), Consumers: q.consumers}, nil
+	}
+
+	q := &memQueue{
+		name:       name,
+		durable:    durable,
+		autoDelete: autoDelete,
+		exclusive:  exclusive,
+		args:       args,
+	}
+	if exclusive {
+		q.owner = ch.conn
+	}
+	b.queues[name] = q
+	return amqp.Queue{Name: name}, nil
+}
+
+func (ch *memChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if _, ok := b.queues[name]; !ok {
+		return &amqp.Error{Code: amqp.NotFound, Reason: fmt.Sprintf("NOT_FOUND - no queue '%s'", name)}
+	}
+	ex, ok := b.exchanges[exchange]
+	if !ok {
+		return &amqp.Error{Code: amqp.NotFound, Reason: fmt.Sprintf("NOT_FOUND - no exchange '%s'", exchange)}
+	}
+	for _, bd := range ex.bindings {
+		if bd.queue == name && bd.key == key {
+			return nil
+		}
+	}
+	ex.bindings = append(ex.bindings, memBinding{queue: name, key: key})
+	return nil
+}
+
+func (ch *memChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	ch.prefetch = prefetchCount
+	return nil
+}
+
+func (ch *memChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return nil, amqp.ErrClosed
+	}
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return nil, &amqp.Error{Code: amqp.NotFound, Reason: fmt.Sprintf("NOT_FOUND - no queue '%s'", queue)}
+	}
+	if q.exclusive && q.owner != ch.conn {
+		return nil, &amqp.Error{Code: amqp.ResourceLocked, Reason: fmt.Sprintf("RESOURCE_LOCKED - cannot obtain exclusive access to queue '%s'", queue)}
+	}
+	if consumer == "" {
+		consumer = b.nextName("ctag-")
+	}
+	if _, ok := ch.consumers[consumer]; ok {
+		return nil, &amqp.Error{Code: amqp.NotAllowed, Reason: fmt.Sprintf("NOT_ALLOWED - attempt to reuse consumer tag '%s'", consumer)}
+	}
+
+	c := &memConsumer{
+		tag:      consumer,
+		queue:    q,
+		autoAck:  autoAck,
+		prefetch: ch.prefetch,
+		done:     make(chan struct{}),
+		out:      make(chan amqp.Delivery),
+	}
+	ch.consumers[consumer] = c
+	q.consumers++
+	q.hadConsumers = true
+	go ch.dispatch(c)
+	return c.out, nil
+}
+
+func (ch *memChannel) Cancel(consumer string, noWait bool) error {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	ch.cancel(consumer)
+	return nil
+}
+
+func (ch *memChannel) Get(queue string, autoAck bool) (amqp.Delivery, bool, error) {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.Delivery{}, false, amqp.ErrClosed
+	}
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return amqp.Delivery{}, false, &amqp.Error{Code: amqp.NotFound, Reason: fmt.Sprintf("NOT_FOUND - no queue '%s'", queue)}
+	}
+	if q.exclusive && q.owner != ch.conn {
+		return amqp.Delivery{}, false, &amqp.Error{Code: amqp.ResourceLocked, Reason: fmt.Sprintf("RESOURCE_LOCKED - cannot obtain exclusive access to queue '%s'", queue)}
+	}
+	b.expire(q)
+	if len(q.ready) == 0 {
+		return amqp.Delivery{}, false, nil
+	}
+
+	m := q.ready[0]
+	q.ready = q.ready[1:]
+	ch.nextTag++
+	tag := ch.nextTag
+	if !autoAck {
+		ch.unacked[tag] = &memUnacked{msg: m, queue: q}
+	}
+	return ch.delivery(nil, tag, m), true, nil
+}
+
+func (ch *memChannel) QueuePurge(name string, noWait bool) (int, error) {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return 0, amqp.ErrClosed
+	}
+	q, ok := b.queues[name]
+	if !ok {
+		return 0, &amqp.Error{Code: amqp.NotFound, Reason: fmt.Sprintf("NOT_FOUND - no queue '%s'", name)}
+	}
+	n := len(q.ready)
+	q.ready = nil
+	return n, nil
+}
+
+func (ch *memChannel) Close() error {
+	ch.closeNotify()
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	ch.close()
+	return nil
+}
+
+func (ch *memChannel) close() {
+	if ch.closed {
+		return
+	}
+	ch.closed = true
+	for tag := range ch.consumers {
+		ch.cancel(tag)
+	}
+
+	// Requeue newest first so the queue ends up in its original order.
+	tags := make([]uint64, 0, len(ch.unacked))
+	for tag := range ch.unacked {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	slices.Reverse(tags)
+	for _, tag := range tags {
+		u := ch.unacked[tag]
+		delete(ch.unacked, tag)
+		ch.broker().requeue(u.queue, u.msg)
+	}
+}
+
+func (ch *memChannel) cancel(tag string) {
+	b := ch.broker()
+	c, ok := ch.consumers[tag]
+	if !ok {
+		return
+	}
+	delete(ch.consumers, tag)
+	c.cancelled = true
+	close(c.done)
+	c.queue.consumers--
+	if c.queue.autoDelete && c.queue.consumers == 0 && c.queue.hadConsumers {
+		b.deleteQueue(c.queue)
+	}
+	b.cond.Broadcast()
+}
+
+func (ch *memChannel) dispatch(c *memConsumer) {
+	defer close(c.out)
+	b := ch.broker()
+	for {
+		b.mu.Lock()
+		for !c.cancelled && (len(c.queue.ready) == 0 || (c.prefetch > 0 && c.inflight >= c.prefetch)) {
+			b.cond.Wait()
+		}
+		if c.cancelled {
+			b.mu.Unlock()
+			return
+		}
+		b.expire(c.queue)
+		if len(c.queue.ready) == 0 {
+			b.mu.Unlock()
+			continue
+		}
+
+		m := c.queue.ready[0]
+		c.queue.ready = c.queue.ready[1:]
+		ch.nextTag++
+		tag := ch.nextTag
+		if !c.autoAck {
+			ch.unacked[tag] = &memUnacked{msg: m, queue: c.queue, consumer: c}
+			c.inflight++
+		}
+		d := ch.delivery(c, tag, m)
+		b.mu.Unlock()
+
+		select {
+		case c.out <- d:
+		case <-c.done:
+			b.mu.Lock()
+			if c.autoAck {
+				b.requeue(c.queue, m)
+			} else if u, ok := ch.unacked[tag]; ok {
+				delete(ch.unacked, tag)
+				b.requeue(u.queue, u.msg)
+			}
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (ch *memChannel) delivery(c *memConsumer, tag uint64, m *memMessage) amqp.Delivery {
+	consumerTag := ""
+	if c != nil {
+		consumerTag = c.tag
+	}
+	return amqp.Delivery{
+		Acknowledger:    ch,
+		Headers:         m.pub.Headers,
+		ContentType:     m.pub.ContentType,
+		ContentEncoding: m.pub.ContentEncoding,
+		DeliveryMode:    m.pub.DeliveryMode,
+		Priority:        m.pub.Priority,
+		CorrelationId:   m.pub.CorrelationId,
+		ReplyTo:         m.pub.ReplyTo,
+		Expiration:      m.pub.Expiration,
+		MessageId:       m.pub.MessageId,
+		Timestamp:       m.pub.Timestamp,
+		Type:            m.pub.Type,
+		UserId:          m.pub.UserId,
+		AppId:           m.pub.AppId,
+		ConsumerTag:     consumerTag,
+		DeliveryTag:     tag,
+		Redelivered:     m.redelivered,
+		Exchange:        m.exchange,
+		RoutingKey:      m.key,
+		Body:            m.pub.Body,
+	}
+}
+
+// settle removes the unacked deliveries covered by tag (and every earlier
+// tag when multiple is set) and hands each to fn.
+func (ch *memChannel) settle(tag uint64, multiple bool, fn func(*memUnacked)) error {
+	b := ch.broker()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	var tags []uint64
+	if multiple {
+		for t := range ch.unacked {
+			if t <= tag {
+				tags = append(tags, t)
+			}
+		}
+	} else if _, ok := ch.unacked[tag]; ok {
+		tags = append(tags, tag)
+	}
+	if len(tags) == 0 {
+		return &amqp.Error{Code: amqp.PreconditionFailed, Reason: fmt.Sprintf("PRECONDITION_FAILED - unknown delivery tag %d", tag)}
+	}
+
+	for _, t := range tags {
+		u := ch.unacked[t]
+		delete(ch.unacked, t)
+		if u.consumer != nil {
+			u.consumer.inflight--
+		}
+		fn(u)
+	}
+	b.cond.Broadcast()
+	return nil
+}
+
+func (ch *memChannel) Ack(tag uint64, multiple bool) error {
+	return ch.settle(tag, multiple, func(*memUnacked) {})
+}
+
+func (ch *memChannel) Nack(tag uint64, multiple, requeue bool) error {
+	b := ch.broker()
+	return ch.settle(tag, multiple, func(u *memUnacked) {
+		if requeue {
+			b.requeue(u.queue, u.msg)
+		} else {
+			b.deadLetter(u.queue, u.msg, "rejected")
+		}
+	})
+}
+
+func (ch *memChannel) Reject(tag uint64, requeue bool) error {
+	return ch.Nack(tag, false, requeue)
+}
@@ -0,0 +1,265 @@
+package pubsub
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func newMemoryChannel(t *testing.T) (*MemoryBroker, *memChannel) {
+	t.Helper()
+	b := NewMemoryBroker()
+	conn := b.Dial()
+	t.Cleanup(func() { conn.Close() })
+	ch, err := conn.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, ch.(*memChannel)
+}
+
+func declare(t *testing.T, ch *memChannel, exchange, kind, queue, key string, args amqp.Table) {
+	t.Helper()
+	if err := ch.ExchangeDeclare(exchange, kind, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, args); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.QueueBind(queue, key, exchange, false, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func publish(t *testing.T, ch *memChannel, exchange, key string, pub amqp.Publishing) {
+	t.Helper()
+	if err := ch.PublishWithContext(context.Background(), exchange, key, false, false, pub); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(t *testing.T, ch *memChannel, queue string) amqp.Delivery {
+	t.Helper()
+	d, ok, err := ch.Get(queue, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatalf("queue %s is empty", queue)
+	}
+	return d
+}
+
+func TestTopicMatch(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"army_moves.*", "army_moves.alice", true},
+		{"army_moves.*", "army_moves", false},
+		{"army_moves.*", "army_moves.alice.bob", false},
+		{"*.alice", "army_moves.alice", true},
+		{"game_logs.#", "game_logs", true},
+		{"game_logs.#", "game_logs.alice", true},
+		{"game_logs.#", "game_logs.alice.bob", true},
+		{"#", "anything.at.all", true},
+		{"#.alice", "a.b.alice", true},
+		{"#.alice", "a.b.bob", false},
+		{"a.#.z", "a.z", true},
+		{"a.#.z", "a.b.c.z", true},
+		{"a.*.z", "a.z", false},
+		{"pause", "pause", true},
+		{"pause", "paused", false},
+	}
+	for _, tt := range tests {
+		got := topicMatch(strings.Split(tt.pattern, "."), strings.Split(tt.key, "."))
+		if got != tt.want {
+			t.Errorf("topicMatch(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryBrokerTopicRouting(t *testing.T) {
+	b, ch := newMemoryChannel(t)
+	declare(t, ch, "topic", amqp.ExchangeTopic, "moves", "army_moves.*", nil)
+	declare(t, ch, "topic", amqp.ExchangeTopic, "logs", "game_logs.#", nil)
+
+	publish(t, ch, "topic", "army_moves.alice", amqp.Publishing{Body: []byte("move")})
+	publish(t, ch, "topic", "army_moves.alice.extra", amqp.Publishing{Body: []byte("too long")})
+	publish(t, ch, "topic", "game_logs.alice.extra", amqp.Publishing{Body: []byte("log")})
+
+	if n := b.QueueLength("moves"); n != 1 {
+		t.Errorf("moves has %v messages, want 1", n)
+	}
+	if n := b.QueueLength("logs"); n != 1 {
+		t.Errorf("logs has %v messages, want 1", n)
+	}
+	if d := get(t, ch, "moves"); string(d.Body) != "move" || d.RoutingKey != "army_moves.alice" {
+		t.Errorf("got %q with key %s", d.Body, d.RoutingKey)
+	}
+}
+
+func TestMemoryBrokerNackRequeue(t *testing.T) {
+	_, ch := newMemoryChannel(t)
+	declare(t, ch, "direct", amqp.ExchangeDirect, "q", "k", nil)
+	publish(t, ch, "direct", "k", amqp.Publishing{Body: []byte("1")})
+	publish(t, ch, "direct", "k", amqp.Publishing{Body: []byte("2")})
+
+	// with one message in flight the second stays in the queue, so the
+	// requeued one can go back ahead of it
+	if err := ch.Qos(1, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := ch.Consume("q", "", false, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := <-deliveries
+	if first.Redelivered {
+		t.Error("first delivery is marked redelivered")
+	}
+	if err := first.Nack(false, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// the requeued message goes back to the head of the queue
+	again := <-deliveries
+	if string(again.Body) != "1" || !again.Redelivered {
+		t.Errorf("got %q, redelivered %v; want \"1\" redelivered", again.Body, again.Redelivered)
+	}
+	if err := again.Ack(false); err != nil {
+		t.Fatal(err)
+	}
+	if second := <-deliveries; string(second.Body) != "2" {
+		t.Errorf("got %q, want \"2\"", second.Body)
+	}
+	if err := first.Ack(false); err == nil {
+		t.Error("acking a settled delivery succeeded")
+	}
+}
+
+func TestMemoryBrokerDeadLetter(t *testing.T) {
+	b, ch := newMemoryChannel(t)
+	declare(t, ch, "dlx", amqp.ExchangeFanout, "dlq", "", nil)
+	declare(t, ch, "direct", amqp.ExchangeDirect, "q", "k", amqp.Table{"x-dead-letter-exchange": "dlx"})
+
+	publish(t, ch, "direct", "k", amqp.Publishing{Body: []byte("bad")})
+	if err := get(t, ch, "q").Nack(false, false); err != nil {
+		t.Fatal(err)
+	}
+	dead := get(t, ch, "dlq")
+	if string(dead.Body) != "bad" {
+		t.Errorf("dead-lettered %q, want \"bad\"", dead.Body)
+	}
+	deaths, _ := dead.Headers["x-death"].([]any)
+	if len(deaths) != 1 {
+		t.Fatalf("x-death has %v entries, want 1", len(deaths))
+	}
+	death := deaths[0].(amqp.Table)
+	if death["queue"] != "q" || death["reason"] != "rejected" || death["count"] != int64(1) || death["exchange"] != "direct" {
+		t.Errorf("x-death = %v", death)
+	}
+	if dead.Headers["x-first-death-queue"] != "q" || dead.Headers["x-first-death-reason"] != "rejected" {
+		t.Errorf("first death headers = %v", dead.Headers)
+	}
+
+	// dying in the same queue for the same reason again bumps the count
+	republish := amqp.Publishing{Headers: dead.Headers, Body: dead.Body}
+	if err := dead.Ack(false); err != nil {
+		t.Fatal(err)
+	}
+	publish(t, ch, "direct", "k", republish)
+	if err := get(t, ch, "q").Nack(false, false); err != nil {
+		t.Fatal(err)
+	}
+	deaths, _ = get(t, ch, "dlq").Headers["x-death"].([]any)
+	if len(deaths) != 1 || deaths[0].(amqp.Table)["count"] != int64(2) {
+		t.Errorf("x-death after a second death = %v", deaths)
+	}
+	if n := b.QueueLength("q"); n != 0 {
+		t.Errorf("q still has %v messages", n)
+	}
+}
+
+func TestMemoryBrokerTTL(t *testing.T) {
+	_, ch := newMemoryChannel(t)
+	declare(t, ch, "dlx", amqp.ExchangeFanout, "dlq", "", nil)
+	declare(t, ch, "direct", amqp.ExchangeDirect, "q", "k", amqp.Table{
+		"x-dead-letter-exchange": "dlx",
+		"x-message-ttl":          int32(20),
+	})
+	declare(t, ch, "direct", amqp.ExchangeDirect, "long", "long", amqp.Table{
+		"x-dead-letter-exchange": "dlx",
+		"x-message-ttl":          int32(60000),
+	})
+
+	publish(t, ch, "direct", "k", amqp.Publishing{Body: []byte("queue ttl")})
+	publish(t, ch, "direct", "long", amqp.Publishing{Body: []byte("message ttl"), Expiration: "20"})
+
+	deliveries, err := ch.Consume("dlq", "", true, false, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for range 2 {
+		select {
+		case d := <-deliveries:
+			got[string(d.Body)] = true
+			if d.Headers["x-first-death-reason"] != "expired" {
+				t.Errorf("%q died of %v, want expired", d.Body, d.Headers["x-first-death-reason"])
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("only %v expired", got)
+		}
+	}
+	if !got["queue ttl"] || !got["message ttl"] {
+		t.Errorf("expired %v", got)
+	}
+}
+
+func TestMemoryBrokerPublisherReturns(t *testing.T) {
+	_, ch := newMemoryChannel(t)
+	declare(t, ch, "direct", amqp.ExchangeDirect, "q", "k", nil)
+	if err := ch.Confirm(false); err != nil {
+		t.Fatal(err)
+	}
+	returns := ch.NotifyReturn(make(chan amqp.Return, 1))
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 2))
+
+	ctx := context.Background()
+	err := ch.PublishWithContext(ctx, "direct", "nowhere", true, false, amqp.Publishing{MessageId: "lost", Body: []byte("x")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ch.PublishWithContext(ctx, "direct", "k", true, false, amqp.Publishing{MessageId: "kept", Body: []byte("y")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-returns:
+		if r.ReplyCode != amqp.NoRoute || r.RoutingKey != "nowhere" || r.MessageId != "lost" {
+			t.Errorf("return = %+v", r)
+		}
+	default:
+		t.Fatal("unroutable mandatory publish was not returned")
+	}
+	select {
+	case r := <-returns:
+		t.Errorf("routable publish was returned: %+v", r)
+	default:
+	}
+	for tag := uint64(1); tag <= 2; tag++ {
+		if c := <-confirms; c.DeliveryTag != tag || !c.Ack {
+			t.Errorf("confirmation = %+v, want ack of %v", c, tag)
+		}
+	}
+
+	err = ch.PublishWithContext(ctx, "missing", "k", false, false, amqp.Publishing{})
+	if amqpErr, ok := err.(*amqp.Error); !ok || amqpErr.Code != amqp.NotFound {
+		t.Errorf("publishing to a missing exchange: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package pubsub
+
+import (
+	"strings"
+
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/metrics"
+)
+
+var (
+	publishedTotal = metrics.NewCounter("peril_published_total",
+		"Messages published, by exchange and routing key prefix.", "exchange", "routing_key")
+	publishErrorsTotal = metrics.NewCounter("peril_publish_errors_total",
+		"Publishes that failed, by exchange and routing key prefix.", "exchange", "routing_key")
+	consumedTotal = metrics.NewCounter("peril_consumed_total",
+		"Messages handled, by queue, the exchange and routing key prefix they were published with, and outcome.",
+		"queue", "exchange", "routing_key", "ack")
+	handlerSeconds = metrics.NewHistogram("peril_handler_duration_seconds",
+		"Time spent in subscription handlers, by queue.", metrics.DefBuckets, "queue")
+	decodeFailuresTotal = metrics.NewCounter("peril_decode_failures_total",
+		"Deliveries quarantined because they could not be decoded, by queue and content type.", "queue", "content_type")
+	handlerPanicsTotal = metrics.NewCounter("peril_handler_panics_total",
+		"Handler panics, by queue.", "queue")
+	reconnectsTotal = metrics.NewCounter("peril_reconnects_total",
+		"Times a managed connection was re-established after being lost.")
+)
+
+// routingKeyLabel collapses a routing key to its first word, so that keys
+// naming a player or an RPC reply queue do not each get their own series:
+// army_moves.alice is counted as army_moves.*.
+func routingKeyLabel(key string) string {
+	prefix, _, found := strings.Cut(key, ".")
+	if !found {
+		return key
+	}
+	return prefix + ".*"
+}
+
+// contentTypeLabel keeps the content type label to the registered codecs,
+// since the sender chooses it: anything else is counted as "other".
+func contentTypeLabel(contentType string) string {
+	if contentType == "" {
+		return "none"
+	}
+	c, ok := CodecFor(contentType)
+	if !ok {
+		return "other"
+	}
+	return c.ContentType()
+}
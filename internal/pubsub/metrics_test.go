@@ -0,0 +1,34 @@
+package pubsub
+
+import "testing"
+
+func TestRoutingKeyLabel(t *testing.T) {
+	tests := map[string]string{
+		"pause":                    "pause",
+		"army_moves.alice":         "army_moves.*",
+		"game_logs.bob":            "game_logs.*",
+		"peril_rpc.reply.0123abcd": "peril_rpc.*",
+		"":                         "",
+	}
+	for key, want := range tests {
+		if got := routingKeyLabel(key); got != want {
+			t.Errorf("routingKeyLabel(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestContentTypeLabel(t *testing.T) {
+	tests := map[string]string{
+		"":                                 "none",
+		"application/json":                 "application/json",
+		"application/json; charset=utf-8":  "application/json",
+		"application/gob":                  "application/gob",
+		"text/x-anything-the-sender-likes": "other",
+		"not a media type {}":              "other",
+	}
+	for contentType, want := range tests {
+		if got := contentTypeLabel(contentType); got != want {
+			t.Errorf("contentTypeLabel(%q) = %q, want %q", contentType, got, want)
+		}
+	}
+}
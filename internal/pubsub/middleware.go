@@ -0,0 +1,103 @@
+package pubsub
+
+import (
+	"errors"
+	"time"
+)
+
+// Message is what middleware sees of a delivery: its envelope, the queue it
+// came from and the decoded value.
+type Message struct {
+	Metadata
+	Queue string
+	Value any
+}
+
+// Handler handles one decoded message.
+type Handler func(Message) Acktype
+
+// Middleware wraps a Handler, typically to do something before or after it.
+type Middleware func(Handler) Handler
+
+// WithMiddleware wraps the subscription's handler. The first middleware is
+// the outermost; calling WithMiddleware again adds further, inner ones.
+func WithMiddleware(mw ...Middleware) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.middleware = append(o.middleware, mw...)
+	}
+}
+
+// Chain composes middleware into one, the first being the outermost.
+func Chain(mw ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		for i := len(mw) - 1; i >= 0; i-- {
+			next = mw[i](next)
+		}
+		return next
+	}
+}
+
+// Logging logs the outcome of every message.
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return func(msg Message) Acktype {
+			ack := next(msg)
+			logger().Info("handled message", "queue", msg.Queue, "routing_key", msg.RoutingKey, "sender", msg.Sender, "message_id", msg.MessageID, "ack", ack)
+			return ack
+		}
+	}
+}
+
+// Tracing logs a span for every message at debug level: the trace it belongs
+// to, the message that caused it and how it was handled.
+func Tracing() Middleware {
+	return func(next Handler) Handler {
+		return func(msg Message) Acktype {
+			start := time.Now()
+			ack := next(msg)
+			logger().Debug("span", "trace_id", msg.TraceID, "span_id", msg.MessageID, "parent_id", msg.CorrelationID,
+				"queue", msg.Queue, "routing_key", msg.RoutingKey, "ack", ack, "took", time.Since(start))
+			return ack
+		}
+	}
+}
+
+var (
+	// ErrDiscard marks an error as permanent; see AckFor.
+	ErrDiscard = errors.New("discard message")
+	// ErrRetryLater marks an error as worth retrying after a delay; see AckFor.
+	ErrRetryLater = errors.New("retry message later")
+)
+
+type ackError struct {
+	err  error
+	kind error
+}
+
+func (e ackError) Error() string   { return e.err.Error() }
+func (e ackError) Unwrap() []error { return []error{e.err, e.kind} }
+
+// Discard wraps err so that AckFor dead-letters the message.
+func Discard(err error) error {
+	return ackError{err: err, kind: ErrDiscard}
+}
+
+// RetryLater wraps err so that AckFor schedules the message for a retry.
+func RetryLater(err error) error {
+	return ackError{err: err, kind: ErrRetryLater}
+}
+
+// AckFor maps a handler error to an Acktype. nil acks, errors marked with
+// Discard or RetryLater get the matching nack, and anything else is assumed
+// to be transient and requeued.
+func AckFor(err error) Acktype {
+	switch {
+	case err == nil:
+		return Ack
+	case errors.Is(err, ErrDiscard):
+		return NackDiscard
+	case errors.Is(err, ErrRetryLater):
+		return NackRetryLater
+	}
+	return NackRequeue
+}
@@ -0,0 +1,52 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	mark := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(msg Message) Acktype {
+				calls = append(calls, name+" before")
+				ack := next(msg)
+				calls = append(calls, name+" after")
+				return ack
+			}
+		}
+	}
+	handler := Chain(mark("outer"), mark("inner"))(func(Message) Acktype {
+		calls = append(calls, "handler")
+		return NackDiscard
+	})
+
+	if ack := handler(Message{}); ack != NackDiscard {
+		t.Errorf("chain returned %v, want the handler's NackDiscard", ack)
+	}
+	want := []string{"outer before", "inner before", "handler", "inner after", "outer after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestAckFor(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Acktype
+	}{
+		{nil, Ack},
+		{errors.New("flaky"), NackRequeue},
+		{Discard(errors.New("bad")), NackDiscard},
+		{fmt.Errorf("wrapped: %w", Discard(errors.New("bad"))), NackDiscard},
+		{RetryLater(errors.New("not yet")), NackRetryLater},
+	}
+	for _, tt := range tests {
+		if got := AckFor(tt.err); got != tt.want {
+			t.Errorf("AckFor(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package pubsub
+
+import amqp "github.com/rabbitmq/amqp091-go"
+
+const defaultPrefetch = 10
+
+type subscribeOptions struct {
+	prefetch     int
+	workers      int
+	orderingKey  func(amqp.Delivery) string
+	retry        RetryPolicy
+	defaultCodec Codec
+	middleware   []Middleware
+}
+
+// SubscribeOption configures a subscription.
+type SubscribeOption func(*subscribeOptions)
+
+func newSubscribeOptions(opts []SubscribeOption) subscribeOptions {
+	o := subscribeOptions{
+		prefetch:     defaultPrefetch,
+		workers:      1,
+		retry:        DefaultRetryPolicy,
+		defaultCodec: JSON,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.workers < 1 {
+		o.workers = 1
+	}
+	if o.prefetch > 0 && o.prefetch < o.workers {
+		o.prefetch = o.workers
+	}
+	return o
+}
+
+// WithPrefetch limits how many unacknowledged deliveries the broker sends
+// the consumer at once. Zero means no limit.
+func WithPrefetch(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.prefetch = n
+	}
+}
+
+// WithWorkers runs the handler on n goroutines instead of one.
+func WithWorkers(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.workers = n
+	}
+}
+
+// WithOrderingKey keeps deliveries that share a key on the same worker, so
+// they are handled in the order they arrived.
+func WithOrderingKey(key func(amqp.Delivery) string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.orderingKey = key
+	}
+}
+
+// ByRoutingKey is an ordering key that serialises deliveries per routing key,
+// e.g. per player for "game_logs.<username>".
+func ByRoutingKey(d amqp.Delivery) string {
+	return OriginalRoutingKey(d)
+}
+
+// WithDefaultCodec sets the codec used for deliveries that carry no content
+// type. Deliveries that do are always decoded by their content type.
+func WithDefaultCodec(c Codec) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.defaultCodec = c
+	}
+}
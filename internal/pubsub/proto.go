@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Proto encodes generated protobuf messages. It is registered for
+// "application/x-protobuf" by default.
+var Proto Codec = protoCodec{}
+
+func init() {
+	RegisterCodec(Proto)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (protoCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a protobuf message", v)
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal accepts either a message or a pointer to a message pointer, which
+// is what decoding into a Subscribe[*pb.Message] handler produces.
+func (protoCodec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.Elem().Kind() == reflect.Pointer {
+		msg := reflect.New(rv.Elem().Type().Elem())
+		if m, ok := msg.Interface().(proto.Message); ok {
+			err := proto.Unmarshal(data, m)
+			if err != nil {
+				return err
+			}
+			rv.Elem().Set(msg)
+			return nil
+		}
+	}
+	return fmt.Errorf("%T is not a protobuf message", v)
+}
+
+func PublishProto[T proto.Message](ctx context.Context, ch Publisher, exchange, key string, msg T, opts ...PublishOption) error {
+	return Publish(ctx, ch, Proto, exchange, key, msg, opts...)
+}
+
+// SubscribeProto is Subscribe that assumes protobuf when no content type is
+// set. T is a generated message pointer such as *perilpb.ArmyMove.
+func SubscribeProto[T proto.Message](
+	ctx context.Context,
+	b Broker,
+	exchange,
+	queueName,
+	key string,
+	simpleQueueType int,
+	handler func(T) Acktype,
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+
+	opts = append([]SubscribeOption{WithDefaultCodec(Proto)}, opts...)
+	return subscribe(ctx, b, exchange, queueName, key, simpleQueueType, withoutMetadata(handler), opts)
+}
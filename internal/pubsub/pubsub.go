@@ -1,12 +1,9 @@
 package pubsub
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
-	"encoding/json"
 	"fmt"
-	"log"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -14,8 +11,15 @@ import (
 const (
 	DurableQueue = iota
 	TransientQueue
+	// SharedTransientQueue does not survive a broker restart, but unlike
+	// TransientQueue it can have several consumers and outlives them.
+	SharedTransientQueue
 )
 
+// DeadLetterExchange is set as the dead-letter exchange of every queue
+// declared by DeclareAndBind.
+var DeadLetterExchange = "peril_dlx"
+
 type Acktype int
 
 func (a Acktype) String() string {
@@ -26,6 +30,8 @@ func (a Acktype) String() string {
 		return "NackRequeue"
 	case NackDiscard:
 		return "NackDiscard"
+	case NackRetryLater:
+		return "NackRetryLater"
 	}
 	return "InvalidAcktype"
 }
@@ -34,88 +40,100 @@ const (
 	Ack Acktype = iota
 	NackRequeue
 	NackDiscard
+	// NackRetryLater redelivers the message after a delay, and dead-letters
+	// it once the subscription's RetryPolicy runs out.
+	NackRetryLater
 )
 
-func SubscribeJSON[T any](
-	conn *amqp.Connection,
+// Subscribe consumes a queue and decodes each delivery with the codec
+// registered for its content type, so producers can move between encodings
+// without every consumer switching at once.
+func Subscribe[T any](
+	ctx context.Context,
+	b Broker,
 	exchange,
 	queueName,
 	key string,
 	simpleQueueType int,
 	handler func(T) Acktype,
-) error {
+	opts ...SubscribeOption,
+) (*Subscription, error) {
 
-	channel, _, err := DeclareAndBind(conn, exchange, queueName, key, simpleQueueType)
-	if err != nil {
-		return fmt.Errorf("binding quque: %v", err)
-	}
+	return subscribe(ctx, b, exchange, queueName, key, simpleQueueType, withoutMetadata(handler), opts)
+}
 
-	// channel.Qos(10, 0, false)
-	deliveries, err := channel.Consume(queueName, "", false, false, false, false, nil)
-	if err != nil {
-		return fmt.Errorf("creating consume channlel: %v", err)
-	}
+// SubscribeWithMetadata is Subscribe for handlers that need to know who sent
+// a message and when. Use WithDefaultCodec for producers that set no content
+// type.
+func SubscribeWithMetadata[T any](
+	ctx context.Context,
+	b Broker,
+	exchange,
+	queueName,
+	key string,
+	simpleQueueType int,
+	handler func(Metadata, T) Acktype,
+	opts ...SubscribeOption,
+) (*Subscription, error) {
 
-	go func() {
-		for d := range deliveries {
-			var message T
-			err := json.Unmarshal(d.Body, &message)
-			if err != nil {
-				fmt.Printf("could not unmarshal message: %v\n", err)
-			}
-			res := handler(message)
-			log.Printf("acknowledge status: %v\n", res)
+	return subscribe(ctx, b, exchange, queueName, key, simpleQueueType, handler, opts)
+}
 
-			switch res {
-			case Ack:
-				err = d.Ack(false)
-				if err != nil {
-					fmt.Printf("could not ack message: %v\n", err)
-				}
-			case NackRequeue:
-				err = d.Nack(false, true)
-				if err != nil {
-					fmt.Printf("could not requeue message: %v\n", err)
-				}
-			case NackDiscard:
-				err = d.Nack(false, false)
-				if err != nil {
-					fmt.Printf("could not discard message: %v\n", err)
-				}
-			}
-		}
-	}()
+func withoutMetadata[T any](handler func(T) Acktype) func(Metadata, T) Acktype {
+	return func(_ Metadata, msg T) Acktype {
+		return handler(msg)
+	}
+}
 
-	return nil
+// SubscribeJSON is Subscribe that assumes JSON when no content type is set.
+func SubscribeJSON[T any](
+	ctx context.Context,
+	b Broker,
+	exchange,
+	queueName,
+	key string,
+	simpleQueueType int,
+	handler func(T) Acktype,
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+
+	opts = append([]SubscribeOption{WithDefaultCodec(JSON)}, opts...)
+	return subscribe(ctx, b, exchange, queueName, key, simpleQueueType, withoutMetadata(handler), opts)
 }
 
-func PublishJSON[T any](ch *amqp.Channel, exchange, key string, val T) error {
-	jsonBytes, err := json.Marshal(val)
+// Publish encodes val with codec and publishes it in an envelope carrying the
+// codec's content type, a fresh message ID, the current time and the schema
+// version.
+func Publish[T any](ctx context.Context, ch Publisher, codec Codec, exchange, key string, val T, opts ...PublishOption) error {
+	body, err := codec.Marshal(val)
 	if err != nil {
-		return fmt.Errorf("could not marshal date: %v", err)
+		return fmt.Errorf("encoding %s: %v", codec.ContentType(), err)
 	}
 
-	msg := amqp.Publishing{
-		ContentType: "application/json",
-		Body:        jsonBytes,
-	}
-	err = ch.PublishWithContext(context.Background(), exchange, key, false, false, msg)
+	msg := envelope(codec.ContentType(), body, opts)
+	err = ch.PublishWithContext(ctx, exchange, key, false, false, msg)
 	if err != nil {
-		return fmt.Errorf("could not publish: %v", err)
+		publishErrorsTotal.With(exchange, routingKeyLabel(key)).Inc()
+		return fmt.Errorf("could not publish: %w", err)
 	}
+	publishedTotal.With(exchange, routingKeyLabel(key)).Inc()
 
 	return nil
 }
 
+func PublishJSON[T any](ctx context.Context, ch Publisher, exchange, key string, val T, opts ...PublishOption) error {
+	return Publish(ctx, ch, JSON, exchange, key, val, opts...)
+}
+
 func DeclareAndBind(
-	conn *amqp.Connection,
+	b Broker,
 	exchange,
 	queueName,
 	key string,
 	simpleQueueType int, // an enum to represent "durable" or "transient"
-) (*amqp.Channel, amqp.Queue, error) {
+) (Channel, amqp.Queue, error) {
 
-	channel, err := conn.Channel()
+	channel, err := b.Channel()
 	if err != nil {
 		return nil, amqp.Queue{}, fmt.Errorf("could not create channel: %v", err)
 	}
@@ -130,10 +148,14 @@ func DeclareAndBind(
 		durable = false
 		autoDelete = true
 		exclusive = true
+	case SharedTransientQueue:
+		durable = false
+		autoDelete = false
+		exclusive = false
 	}
 
 	table := make(amqp.Table)
-	table["x-dead-letter-exchange"] = "peril_dlx"
+	table["x-dead-letter-exchange"] = DeadLetterExchange
 	queue, err := channel.QueueDeclare(queueName, durable, autoDelete, exclusive, false, table)
 	if err != nil {
 		return nil, amqp.Queue{}, fmt.Errorf("could not create queue: %v", err)
@@ -147,92 +169,103 @@ func DeclareAndBind(
 	return channel, queue, nil
 }
 
-func PublishGob[T any](ch *amqp.Channel, exchange, key string, val T) error {
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	err := encoder.Encode(val)
-	if err != nil {
-		return fmt.Errorf("encoding to gob: %v", err)
-	}
-
-	msg := amqp.Publishing{
-		ContentType: "application/gob",
-		Body:        buffer.Bytes(),
-	}
-
-	err = ch.PublishWithContext(context.Background(), exchange, key, false, false, msg)
-	if err != nil {
-		return fmt.Errorf("publishing gob: %v", err)
-	}
-	return nil
+func PublishGob[T any](ctx context.Context, ch Publisher, exchange, key string, val T, opts ...PublishOption) error {
+	return Publish(ctx, ch, Gob, exchange, key, val, opts...)
 }
 
-func SubscribeGob[T any](conn *amqp.Connection,
+// SubscribeGob is Subscribe that assumes gob when no content type is set.
+func SubscribeGob[T any](
+	ctx context.Context,
+	b Broker,
 	exchange,
 	queueName,
 	key string,
 	simpleQueueType int,
 	handler func(T) Acktype,
-) error {
-
-	return subscribe(conn, exchange, queueName, key, simpleQueueType, handler, func(data []byte) (T, error) {
-		buffer := bytes.NewBuffer(data)
-		decoder := gob.NewDecoder(buffer)
-		var msg T
-		err := decoder.Decode(&msg)
-		return msg, err
-	})
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+
+	opts = append([]SubscribeOption{WithDefaultCodec(Gob)}, opts...)
+	return subscribe(ctx, b, exchange, queueName, key, simpleQueueType, withoutMetadata(handler), opts)
 }
 
 func subscribe[T any](
-	conn *amqp.Connection,
+	ctx context.Context,
+	b Broker,
 	exchange,
 	queueName,
 	key string,
 	simpleQueueType int,
-	handler func(T) Acktype,
-	unmarshaller func([]byte) (T, error),
-) error {
+	handler func(Metadata, T) Acktype,
+	opts []SubscribeOption,
+) (*Subscription, error) {
 
-	channel, _, err := DeclareAndBind(conn, exchange, queueName, key, simpleQueueType)
+	o := newSubscribeOptions(opts)
+	channel, _, err := DeclareAndBind(b, exchange, queueName, key, simpleQueueType)
 	if err != nil {
-		return fmt.Errorf("binding to queue: %v", err)
+		return nil, fmt.Errorf("binding to queue: %v", err)
 	}
 
-	// channel.Qos(10, 0, false)
-	deliveries, err := channel.Consume(queueName, "", false, false, false, false, nil)
+	err = channel.Qos(o.prefetch, 0, false)
+	if err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("setting prefetch: %v", err)
+	}
+	tag := fmt.Sprintf("%s-%d", queueName, consumerSerial.Add(1))
+	deliveries, err := channel.Consume(queueName, tag, false, false, false, false, nil)
+	if err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("consuming queue: %v", err)
+	}
+
+	handle := Chain(o.middleware...)(func(msg Message) Acktype {
+		val, _ := msg.Value.(T)
+		return handler(msg.Metadata, val)
+	})
+	retrier := newRetrier(b, channel, queueName, simpleQueueType == DurableQueue, o.retry)
+	quarantine := newQuarantiner(channel, queueName)
+	sup := newSupervisor(channel, queueName)
+	sub := newSubscription(ctx, channel, tag, sup)
 	go func() {
-		for d := range deliveries {
-			msg, err := unmarshaller(d.Body)
+		defer sub.finish()
+		defer retrier.close()
+		consume(deliveries, o, sup, func(d amqp.Delivery) {
+			msg, err := decode[T](d.ContentType, d.Body, o.defaultCodec)
 			if err != nil {
-				log.Printf("unmarshaling delivery: %v", err)
+				logger().Warn("could not decode delivery, quarantining it", "queue", queueName, "content_type", d.ContentType, "err", err)
+				decodeFailuresTotal.With(queueName, contentTypeLabel(d.ContentType)).Inc()
+				quarantine.put(d, err)
+				return
 			}
 
-			ackStatus := handler(msg)
+			meta := metadataOf(d)
+			start := time.Now()
+			ackStatus := handle(Message{Metadata: meta, Queue: queueName, Value: msg})
+			handlerSeconds.With(queueName).Observe(time.Since(start).Seconds())
+			consumedTotal.With(queueName, meta.Exchange, routingKeyLabel(meta.RoutingKey), ackStatus.String()).Inc()
 			switch ackStatus {
 			case Ack:
 				err := d.Ack(false)
 				if err != nil {
-					log.Printf("could not ack delivery: %v", err)
+					logger().Error("could not settle delivery", "queue", queueName, "ack", ackStatus, "err", err)
 				}
 			case NackDiscard:
 				err := d.Nack(false, false)
 				if err != nil {
-					log.Printf("could not ack delivery: %v", err)
+					logger().Error("could not settle delivery", "queue", queueName, "ack", ackStatus, "err", err)
 				}
 			case NackRequeue:
 				err := d.Nack(false, true)
 				if err != nil {
-					log.Printf("could not ack delivery: %v", err)
+					logger().Error("could not settle delivery", "queue", queueName, "ack", ackStatus, "err", err)
 				}
 			default:
-				err := d.Nack(false, true)
-				if err != nil {
-					log.Printf("could not ack delivery: %v", err)
-				}
+				// NackRetryLater, or anything unexpected: back off rather
+				// than spin the message between consumers
+				retrier.retry(d)
 			}
-		}
+		})
 	}()
 
-	return nil
+	return sub, nil
 }
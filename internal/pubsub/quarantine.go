@@ -0,0 +1,250 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// QuarantineQueue holds deliveries that could not be decoded. They are kept
+// out of the dead-letter queue so they can be inspected and replayed once
+// the consumer is fixed.
+const QuarantineQueue = "peril_quarantine"
+
+const (
+	quarantineIDHeader    = "x-peril-quarantine-id"
+	quarantineErrorHeader = "x-peril-error"
+	quarantineTimeHeader  = "x-peril-quarantined-at"
+)
+
+// ErrNotQuarantined is returned when no quarantined message has the given ID.
+var ErrNotQuarantined = errors.New("no quarantined message with that id")
+
+type QuarantinedMessage struct {
+	ID            string
+	Queue         string
+	Exchange      string
+	RoutingKey    string
+	ContentType   string
+	Error         string
+	QuarantinedAt time.Time
+	Body          []byte
+	Headers       amqp.Table
+}
+
+type quarantiner struct {
+	channel Channel
+	queue   string
+
+	mu       sync.Mutex
+	declared bool
+}
+
+func newQuarantiner(channel Channel, queue string) *quarantiner {
+	return &quarantiner{channel: channel, queue: queue}
+}
+
+func (q *quarantiner) declare() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.declared {
+		return nil
+	}
+	_, err := q.channel.QueueDeclare(QuarantineQueue, true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	q.declared = true
+	return nil
+}
+
+// put moves an undecodable delivery to the quarantine queue. d is always
+// settled; if quarantining fails it is dead-lettered instead.
+func (q *quarantiner) put(d amqp.Delivery, decodeErr error) {
+	err := q.declare()
+	if err == nil {
+		headers := copyHeaders(d)
+		headers[quarantineIDHeader] = newID()
+		headers[quarantineErrorHeader] = decodeErr.Error()
+		headers[originalQueueHeader] = q.queue
+		headers[quarantineTimeHeader] = time.Now().UTC().Format(time.RFC3339)
+
+		err = q.channel.PublishWithContext(context.Background(), "", QuarantineQueue, false, false, amqp.Publishing{
+			Headers:       headers,
+			ContentType:   d.ContentType,
+			DeliveryMode:  amqp.Persistent,
+			MessageId:     d.MessageId,
+			Timestamp:     d.Timestamp,
+			AppId:         d.AppId,
+			CorrelationId: d.CorrelationId,
+			Body:          d.Body,
+		})
+	}
+	if err != nil {
+		logger().Error("could not quarantine delivery, dead-lettering it", "queue", q.queue, "err", err)
+		err = d.Nack(false, false)
+		if err != nil {
+			logger().Error("could not dead-letter delivery", "queue", q.queue, "err", err)
+		}
+		return
+	}
+
+	err = d.Ack(false)
+	if err != nil {
+		logger().Error("could not ack quarantined delivery", "queue", q.queue, "err", err)
+	}
+}
+
+type queueReader interface {
+	Channel
+	Get(queue string, autoAck bool) (amqp.Delivery, bool, error)
+	QueuePurge(name string, noWait bool) (int, error)
+}
+
+func readerChannel(b Broker) (queueReader, error) {
+	ch, err := b.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("could not create channel: %v", err)
+	}
+	reader, ok := ch.(queueReader)
+	if !ok {
+		ch.Close()
+		return nil, errors.New("channel does not support reading queues")
+	}
+	_, err = reader.QueueDeclare(QuarantineQueue, true, false, false, false, nil)
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("declaring quarantine queue: %v", err)
+	}
+	return reader, nil
+}
+
+// ListQuarantine returns every quarantined message without removing any.
+func ListQuarantine(b Broker) ([]QuarantinedMessage, error) {
+	ch, err := readerChannel(b)
+	if err != nil {
+		return nil, err
+	}
+	// closing the channel returns everything we fetched to the queue
+	defer ch.Close()
+
+	var msgs []QuarantinedMessage
+	for {
+		d, ok, err := ch.Get(QuarantineQueue, false)
+		if err != nil {
+			return nil, fmt.Errorf("reading quarantine: %v", err)
+		}
+		if !ok {
+			return msgs, nil
+		}
+		msgs = append(msgs, quarantinedMessage(d))
+	}
+}
+
+// ReplayQuarantined republishes a quarantined message straight to the queue
+// it was quarantined from, through the default exchange, and removes it from
+// quarantine. Other queues bound to the original exchange do not see it again.
+func ReplayQuarantined(ctx context.Context, b Broker, id string) error {
+	return takeQuarantined(b, id, func(ch queueReader, d amqp.Delivery) error {
+		msg := quarantinedMessage(d)
+		if msg.Queue == "" {
+			return fmt.Errorf("quarantined message %s does not record its queue", id)
+		}
+		// keep where it was first published, so handlers see the same
+		// exchange and routing key as the first time
+		headers := amqp.Table{}
+		for k, v := range d.Headers {
+			if !strings.HasPrefix(k, "x-peril-") || k == originalExchangeHeader || k == originalRoutingKeyHeader {
+				headers[k] = v
+			}
+		}
+		return ch.PublishWithContext(ctx, "", msg.Queue, false, false, amqp.Publishing{
+			Headers:       headers,
+			ContentType:   d.ContentType,
+			DeliveryMode:  amqp.Persistent,
+			MessageId:     d.MessageId,
+			Timestamp:     d.Timestamp,
+			AppId:         d.AppId,
+			CorrelationId: d.CorrelationId,
+			Body:          d.Body,
+		})
+	})
+}
+
+// DeleteQuarantined drops a single quarantined message.
+func DeleteQuarantined(b Broker, id string) error {
+	return takeQuarantined(b, id, func(queueReader, amqp.Delivery) error {
+		return nil
+	})
+}
+
+// PurgeQuarantine drops every quarantined message and reports how many.
+func PurgeQuarantine(b Broker) (int, error) {
+	ch, err := readerChannel(b)
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+	return ch.QueuePurge(QuarantineQueue, false)
+}
+
+// takeQuarantined finds the message with the given id, runs fn on it and
+// acks it if fn succeeds. Every other message is returned to the queue.
+func takeQuarantined(b Broker, id string, fn func(queueReader, amqp.Delivery) error) error {
+	ch, err := readerChannel(b)
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	for {
+		d, ok, err := ch.Get(QuarantineQueue, false)
+		if err != nil {
+			return fmt.Errorf("reading quarantine: %v", err)
+		}
+		if !ok {
+			return ErrNotQuarantined
+		}
+		if d.Headers[quarantineIDHeader] != id {
+			continue
+		}
+
+		err = fn(ch, d)
+		if err != nil {
+			return err
+		}
+		return d.Ack(false)
+	}
+}
+
+func quarantinedMessage(d amqp.Delivery) QuarantinedMessage {
+	str := func(key string) string {
+		s, _ := d.Headers[key].(string)
+		return s
+	}
+	at, _ := time.Parse(time.RFC3339, str(quarantineTimeHeader))
+	return QuarantinedMessage{
+		ID:            str(quarantineIDHeader),
+		Queue:         str(originalQueueHeader),
+		Exchange:      str(originalExchangeHeader),
+		RoutingKey:    str(originalRoutingKeyHeader),
+		ContentType:   d.ContentType,
+		Error:         str(quarantineErrorHeader),
+		QuarantinedAt: at,
+		Body:          d.Body,
+		Headers:       d.Headers,
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
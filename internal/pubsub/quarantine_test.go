@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestQuarantineReplay(t *testing.T) {
+	b, ch := newMemoryChannel(t)
+	declare(t, ch, "peril_topic", "topic", "moves", "army_moves.*", nil)
+	declare(t, ch, "peril_topic", "topic", "audit", "army_moves.*", nil)
+	publish(t, ch, "peril_topic", "army_moves.alice", amqp.Publishing{
+		ContentType: "application/json",
+		MessageId:   "m1",
+		Body:        []byte("{not json"),
+	})
+	get(t, ch, "audit").Ack(false)
+
+	newQuarantiner(ch, "moves").put(get(t, ch, "moves"), errors.New("bad json"))
+
+	conn := b.Dial()
+	defer conn.Close()
+	msgs, err := ListQuarantine(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("got %d quarantined messages, want 1", len(msgs))
+	}
+	msg := msgs[0]
+	if msg.Queue != "moves" || msg.Exchange != "peril_topic" || msg.RoutingKey != "army_moves.alice" {
+		t.Errorf("quarantined from %s via %s %s, want moves via peril_topic army_moves.alice", msg.Queue, msg.Exchange, msg.RoutingKey)
+	}
+	if msg.Error != "bad json" || string(msg.Body) != "{not json" {
+		t.Errorf("got error %q and body %q", msg.Error, msg.Body)
+	}
+	if msgs, _ := ListQuarantine(conn); len(msgs) != 1 {
+		t.Fatalf("listing removed messages: %d left", len(msgs))
+	}
+
+	err = ReplayQuarantined(context.Background(), conn, "missing")
+	if !errors.Is(err, ErrNotQuarantined) {
+		t.Fatalf("replaying an unknown id: got %v, want ErrNotQuarantined", err)
+	}
+	err = ReplayQuarantined(context.Background(), conn, msg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := get(t, ch, "moves")
+	if d.MessageId != "m1" || string(d.Body) != "{not json" {
+		t.Errorf("replayed %s %q", d.MessageId, d.Body)
+	}
+	if d.DeliveryMode != amqp.Persistent {
+		t.Errorf("replayed with delivery mode %d, want persistent", d.DeliveryMode)
+	}
+	meta := metadataOf(d)
+	if meta.Exchange != "peril_topic" || meta.RoutingKey != "army_moves.alice" {
+		t.Errorf("replay looks published via %s %s", meta.Exchange, meta.RoutingKey)
+	}
+	if _, ok := d.Headers[quarantineIDHeader]; ok {
+		t.Error("replay still carries the quarantine id")
+	}
+	if _, ok, _ := ch.Get("audit", false); ok {
+		t.Error("replay reached another queue bound to the exchange")
+	}
+	if msgs, _ := ListQuarantine(conn); len(msgs) != 0 {
+		t.Errorf("%d messages left in quarantine after replay", len(msgs))
+	}
+}
@@ -0,0 +1,233 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	retriesHeader            = "x-peril-retries"
+	originalExchangeHeader   = "x-peril-original-exchange"
+	originalRoutingKeyHeader = "x-peril-original-routing-key"
+	originalQueueHeader      = "x-peril-original-queue"
+)
+
+// retryPublishTimeout bounds the wait for the broker to confirm a retry.
+const retryPublishTimeout = 10 * time.Second
+
+// RetryPolicy controls how NackRetryLater is handled. The n-th retry waits
+// Delays[n-1] (the last delay repeats), and once a message has been retried
+// MaxAttempts times it is dead-lettered instead.
+type RetryPolicy struct {
+	Delays      []time.Duration
+	MaxAttempts int
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Delays:      []time.Duration{time.Second, 5 * time.Second, 30 * time.Second},
+	MaxAttempts: 5,
+}
+
+// WithRetry sets the policy applied when the handler returns NackRetryLater.
+func WithRetry(policy RetryPolicy) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.retry = policy
+	}
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	if len(p.Delays) == 0 {
+		return time.Second
+	}
+	if attempt >= len(p.Delays) {
+		return p.Delays[len(p.Delays)-1]
+	}
+	return p.Delays[attempt]
+}
+
+// retrier parks deliveries in per-delay retry queues. A retry queue has no
+// consumers; its messages expire after the delay and are dead-lettered
+// through the default exchange straight back to the original queue.
+// Retries are published on a confirming channel of their own, opened on the
+// first retry, so a delivery is only acked once its copy is safe.
+type retrier struct {
+	broker  Broker
+	channel Channel
+	queue   string
+	durable bool
+	policy  RetryPolicy
+
+	mu        sync.Mutex
+	declared  map[time.Duration]string
+	publisher *ConfirmingPublisher
+}
+
+func newRetrier(b Broker, channel Channel, queue string, durable bool, policy RetryPolicy) *retrier {
+	return &retrier{
+		broker:   b,
+		channel:  channel,
+		queue:    queue,
+		durable:  durable,
+		policy:   policy,
+		declared: map[time.Duration]string{},
+	}
+}
+
+func (r *retrier) retryQueue(delay time.Duration) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name, ok := r.declared[delay]; ok {
+		return name, nil
+	}
+
+	name := fmt.Sprintf("%s.retry.%dms", r.queue, delay.Milliseconds())
+	args := amqp.Table{
+		"x-message-ttl":             delay.Milliseconds(),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": r.queue,
+		// drop retry queues of players who have left, but never before
+		// their messages had a chance to expire
+		"x-expires": (delay + time.Hour).Milliseconds(),
+	}
+	_, err := r.channel.QueueDeclare(name, r.durable, false, false, false, args)
+	if err != nil {
+		return "", err
+	}
+	r.declared[delay] = name
+	return name, nil
+}
+
+func (r *retrier) confirming() (*ConfirmingPublisher, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.publisher == nil {
+		p, err := NewConfirmingPublisher(r.broker)
+		if err != nil {
+			return nil, err
+		}
+		r.publisher = p
+	}
+	return r.publisher, nil
+}
+
+// publish publishes a retry and waits for the broker to confirm it. A
+// publisher whose channel has gone is dropped so the next retry opens
+// another.
+func (r *retrier) publish(queue string, msg amqp.Publishing) error {
+	p, err := r.confirming()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), retryPublishTimeout)
+	defer cancel()
+	err = p.PublishWithContext(ctx, "", queue, true, false, msg)
+	if errors.Is(err, amqp.ErrClosed) {
+		r.mu.Lock()
+		if r.publisher == p {
+			r.publisher = nil
+		}
+		r.mu.Unlock()
+		p.Close()
+	}
+	return err
+}
+
+// close closes the retry publisher, if one was opened.
+func (r *retrier) close() {
+	r.mu.Lock()
+	p := r.publisher
+	r.publisher = nil
+	r.mu.Unlock()
+	if p != nil {
+		p.Close()
+	}
+}
+
+// retry schedules d for redelivery, or dead-letters it once the policy is
+// exhausted. d is always settled.
+func (r *retrier) retry(d amqp.Delivery) {
+	attempts := retryCount(d)
+	if attempts >= r.policy.MaxAttempts {
+		logger().Warn("giving up on delivery", "queue", r.queue, "message_id", d.MessageId, "retries", attempts)
+		err := d.Nack(false, false)
+		if err != nil {
+			logger().Error("could not dead-letter delivery", "queue", r.queue, "err", err)
+		}
+		return
+	}
+
+	queue, err := r.retryQueue(r.policy.delay(attempts))
+	if err == nil {
+		err = r.publish(queue, retryPublishing(d, attempts+1))
+	}
+	if err != nil {
+		logger().Warn("could not schedule retry, requeueing", "queue", r.queue, "err", err)
+		err = d.Nack(false, true)
+		if err != nil {
+			logger().Error("could not requeue delivery", "queue", r.queue, "err", err)
+		}
+		return
+	}
+
+	err = d.Ack(false)
+	if err != nil {
+		logger().Error("could not ack retried delivery", "queue", r.queue, "err", err)
+	}
+}
+
+func retryCount(d amqp.Delivery) int {
+	return intHeader(d.Headers, retriesHeader)
+}
+
+func retryPublishing(d amqp.Delivery, attempts int) amqp.Publishing {
+	headers := copyHeaders(d)
+	headers[retriesHeader] = int64(attempts)
+	return republishing(d, headers)
+}
+
+// copyHeaders copies d's headers, recording where d was first published if
+// it is not already recorded.
+func copyHeaders(d amqp.Delivery) amqp.Table {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	if _, ok := headers[originalRoutingKeyHeader]; !ok {
+		headers[originalExchangeHeader] = d.Exchange
+		headers[originalRoutingKeyHeader] = d.RoutingKey
+	}
+	return headers
+}
+
+// republishing is d as a new publishing with the given headers.
+func republishing(d amqp.Delivery, headers amqp.Table) amqp.Publishing {
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    d.DeliveryMode,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		ReplyTo:         d.ReplyTo,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		UserId:          d.UserId,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	}
+}
+
+// OriginalRoutingKey is the routing key d was first published with, which
+// differs from d.RoutingKey once it has been through a retry queue.
+func OriginalRoutingKey(d amqp.Delivery) string {
+	if key, ok := d.Headers[originalRoutingKeyHeader].(string); ok {
+		return key
+	}
+	return d.RoutingKey
+}
@@ -0,0 +1,154 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// subscribeRetrying subscribes to army_moves.* on queue moves and reports the
+// metadata of every delivery the handler sees. The handler asks for a retry
+// until the message has been retried succeedAfter times.
+func subscribeRetrying(t *testing.T, policy RetryPolicy, succeedAfter int) (*MemoryBroker, *memChannel, <-chan Metadata) {
+	t.Helper()
+	b, ch := newMemoryChannel(t)
+	declare(t, ch, DeadLetterExchange, amqp.ExchangeFanout, "dlq", "", nil)
+	if err := ch.ExchangeDeclare("peril_topic", amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(chan Metadata, 10)
+	conn := b.Dial()
+	t.Cleanup(func() { conn.Close() })
+	sub, err := SubscribeWithMetadata(context.Background(), conn, "peril_topic", "moves", "army_moves.*", SharedTransientQueue,
+		func(meta Metadata, move string) Acktype {
+			seen <- meta
+			if meta.Retries < succeedAfter {
+				return NackRetryLater
+			}
+			return Ack
+		},
+		WithRetry(policy),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sub.Close)
+
+	err = PublishJSON(context.Background(), ch, "peril_topic", "army_moves.alice", "north")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, ch, seen
+}
+
+func nextDelivery(t *testing.T, seen <-chan Metadata) Metadata {
+	t.Helper()
+	select {
+	case meta := <-seen:
+		return meta
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a delivery")
+		return Metadata{}
+	}
+}
+
+func TestRetryRedelivers(t *testing.T) {
+	policy := RetryPolicy{Delays: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, MaxAttempts: 5}
+	b, _, seen := subscribeRetrying(t, policy, 3)
+
+	for want := 0; want <= 3; want++ {
+		meta := nextDelivery(t, seen)
+		if meta.Retries != want {
+			t.Errorf("delivery %d has been retried %d times", want, meta.Retries)
+		}
+		if meta.Exchange != "peril_topic" || meta.RoutingKey != "army_moves.alice" {
+			t.Errorf("retry %d looks published via %s %s", want, meta.Exchange, meta.RoutingKey)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for name, ttl := range map[string]int64{"moves.retry.10ms": 10, "moves.retry.20ms": 20} {
+		q, ok := b.queues[name]
+		if !ok {
+			t.Errorf("no retry queue %s", name)
+			continue
+		}
+		if q.args["x-message-ttl"] != ttl {
+			t.Errorf("%s has ttl %v, want %d", name, q.args["x-message-ttl"], ttl)
+		}
+		if q.args["x-dead-letter-exchange"] != "" || q.args["x-dead-letter-routing-key"] != "moves" {
+			t.Errorf("%s dead-letters to %q %q, want the default exchange and moves", name, q.args["x-dead-letter-exchange"], q.args["x-dead-letter-routing-key"])
+		}
+	}
+	if len(b.queues["moves"].ready) != 0 || len(b.queues["dlq"].ready) != 0 {
+		t.Error("the message was left behind")
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	policy := RetryPolicy{Delays: []time.Duration{10 * time.Millisecond}, MaxAttempts: 2}
+	_, ch, seen := subscribeRetrying(t, policy, 100)
+
+	for want := 0; want <= 2; want++ {
+		if meta := nextDelivery(t, seen); meta.Retries != want {
+			t.Errorf("delivery %d has been retried %d times", want, meta.Retries)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		d, ok, err := ch.Get("dlq", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			if retryCount(d) != 2 || OriginalRoutingKey(d) != "army_moves.alice" {
+				t.Errorf("dead-lettered after %d retries with key %s", retryCount(d), OriginalRoutingKey(d))
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the message was never dead-lettered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case meta := <-seen:
+		t.Errorf("handled again after giving up: %+v", meta)
+	default:
+	}
+}
+
+// brokenBroker cannot open channels.
+type brokenBroker struct{}
+
+func (brokenBroker) Channel() (Channel, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (brokenBroker) Close() error {
+	return nil
+}
+
+func TestRetryRequeuesWhenPublishFails(t *testing.T) {
+	_, ch := newMemoryChannel(t)
+	declare(t, ch, "direct", amqp.ExchangeDirect, "moves", "moves", nil)
+	publish(t, ch, "direct", "moves", amqp.Publishing{Body: []byte("north")})
+
+	r := newRetrier(brokenBroker{}, ch, "moves", false, DefaultRetryPolicy)
+	defer r.close()
+	r.retry(get(t, ch, "moves"))
+
+	d := get(t, ch, "moves")
+	if string(d.Body) != "north" || !d.Redelivered {
+		t.Errorf("got %q, redelivered %v; want the original back in its queue", d.Body, d.Redelivered)
+	}
+	if retryCount(d) != 0 {
+		t.Errorf("requeued copy counts %d retries", retryCount(d))
+	}
+}
@@ -0,0 +1,197 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// rpcErrorHeader carries the error returned by a Serve handler.
+const rpcErrorHeader = "x-peril-rpc-error"
+
+const rpcReplyQueuePrefix = "peril_rpc.reply."
+
+// ErrRPCClientClosed is returned by calls waiting on a closed RPCClient.
+var ErrRPCClientClosed = errors.New("rpc client closed")
+
+// RemoteError is an error returned by the handler on the serving side.
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return "remote: " + e.Message
+}
+
+// RPCClient sends requests and matches up their replies, which arrive on a
+// reply queue private to the client.
+type RPCClient struct {
+	channel    Channel
+	replyQueue string
+	tag        string
+	codec      Codec
+
+	mu      sync.Mutex
+	pending map[string]chan amqp.Delivery
+	done    chan struct{}
+}
+
+// NewRPCClient opens a channel on b for requests and replies. Requests are
+// encoded with codec.
+func NewRPCClient(b Broker, codec Codec) (*RPCClient, error) {
+	channel, err := b.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("could not create channel: %v", err)
+	}
+	// The name is chosen here rather than by the broker so that it survives
+	// a managed connection redeclaring it after a reconnect.
+	replyQueue := rpcReplyQueuePrefix + newID()
+	_, err = channel.QueueDeclare(replyQueue, false, true, true, false, nil)
+	if err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("declaring reply queue: %v", err)
+	}
+	tag := fmt.Sprintf("%s-%d", replyQueue, consumerSerial.Add(1))
+	replies, err := channel.Consume(replyQueue, tag, true, true, false, false, nil)
+	if err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("consuming reply queue: %v", err)
+	}
+
+	c := &RPCClient{
+		channel:    channel,
+		replyQueue: replyQueue,
+		tag:        tag,
+		codec:      codec,
+		pending:    map[string]chan amqp.Delivery{},
+		done:       make(chan struct{}),
+	}
+	go c.dispatch(replies)
+	return c, nil
+}
+
+func (c *RPCClient) dispatch(replies <-chan amqp.Delivery) {
+	defer close(c.done)
+	for d := range replies {
+		c.mu.Lock()
+		waiting, ok := c.pending[d.CorrelationId]
+		delete(c.pending, d.CorrelationId)
+		c.mu.Unlock()
+		if !ok {
+			// the caller gave up before the reply arrived
+			continue
+		}
+		waiting <- d
+	}
+}
+
+func (c *RPCClient) Close() error {
+	err := c.channel.Cancel(c.tag, false)
+	if err != nil && !errors.Is(err, amqp.ErrClosed) {
+		logger().Warn("could not cancel reply consumer", "err", err)
+	}
+	return c.channel.Close()
+}
+
+// Call publishes req and waits for the reply, the context's deadline or the
+// client being closed. An error returned by the server's handler comes back
+// as a *RemoteError.
+func Call[Req, Resp any](ctx context.Context, c *RPCClient, exchange, key string, req Req) (Resp, error) {
+	var resp Resp
+	id := newID()
+	waiting := make(chan amqp.Delivery, 1)
+	c.mu.Lock()
+	c.pending[id] = waiting
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	err := Publish(ctx, c.channel, c.codec, exchange, key, req,
+		WithCorrelationID(id),
+		WithReplyTo(c.replyQueue),
+	)
+	if err != nil {
+		return resp, err
+	}
+
+	select {
+	case d := <-waiting:
+		if msg, ok := d.Headers[rpcErrorHeader].(string); ok {
+			return resp, &RemoteError{Message: msg}
+		}
+		resp, err = decode[Resp](d.ContentType, d.Body, c.codec)
+		if err != nil {
+			return resp, fmt.Errorf("decoding reply: %v", err)
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return resp, ctx.Err()
+	case <-c.done:
+		return resp, ErrRPCClientClosed
+	}
+}
+
+// Serve answers requests arriving on queueName with handler's result. The
+// reply is encoded like the request and goes straight to the caller's reply
+// queue; a handler error is sent back instead of a result. Requests are
+// acked once handled, even if the reply could not be published, so that
+// handlers run at most once per request.
+func Serve[Req, Resp any](
+	ctx context.Context,
+	b Broker,
+	exchange,
+	queueName,
+	key string,
+	simpleQueueType int,
+	handler func(context.Context, Req) (Resp, error),
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+
+	replies, err := b.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("could not create channel: %v", err)
+	}
+
+	sub, err := subscribe(ctx, b, exchange, queueName, key, simpleQueueType, func(meta Metadata, req Req) Acktype {
+		if meta.ReplyTo == "" {
+			logger().Warn("discarding request without a reply queue", "queue", queueName, "message_id", meta.MessageID)
+			return NackDiscard
+		}
+		codec, ok := CodecFor(meta.ContentType)
+		if !ok {
+			codec = JSON
+		}
+
+		resp, err := handler(ctx, req)
+		replyOpts := []PublishOption{
+			WithCorrelationID(meta.CorrelationID),
+			WithTraceID(meta.TraceID),
+		}
+		if err != nil {
+			replyOpts = append(replyOpts, WithHeader(rpcErrorHeader, err.Error()))
+		}
+		err = Publish(ctx, replies, codec, "", meta.ReplyTo, resp, replyOpts...)
+		if err != nil {
+			// Running the handler again could repeat what it did, and the
+			// caller gives up waiting on its own.
+			logger().Error("could not reply", "queue", queueName, "message_id", meta.MessageID, "err", err)
+		}
+		return Ack
+	}, opts)
+	if err != nil {
+		replies.Close()
+		return nil, err
+	}
+
+	go func() {
+		<-sub.Done()
+		replies.Close()
+	}()
+	return sub, nil
+}
@@ -0,0 +1,114 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func newRPCBroker(t *testing.T) *MemoryConnection {
+	t.Helper()
+	conn := NewMemoryBroker().Dial()
+	t.Cleanup(func() { conn.Close() })
+	ch, err := conn.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ch.Close()
+	if err := ch.ExchangeDeclare("direct", amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestRPCRoundTrip(t *testing.T) {
+	conn := newRPCBroker(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := Serve(ctx, conn, "direct", "double", "double", SharedTransientQueue, func(_ context.Context, n int) (int, error) {
+		if n < 0 {
+			return 0, errors.New("negative")
+		}
+		return 2 * n, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	client, err := NewRPCClient(conn, JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	got, err := Call[int, int](ctx, client, "direct", "double", 21)
+	if err != nil || got != 42 {
+		t.Errorf("Call(21) = %v, %v; want 42", got, err)
+	}
+	_, err = Call[int, int](ctx, client, "direct", "double", -1)
+	var remote *RemoteError
+	if !errors.As(err, &remote) || remote.Message != "negative" {
+		t.Errorf("Call(-1) error = %v, want the handler's error", err)
+	}
+}
+
+// failingReplies hands out a first channel, the one Serve replies on, that
+// cannot publish.
+type failingReplies struct {
+	Broker
+	handedOut atomic.Bool
+}
+
+func (b *failingReplies) Channel() (Channel, error) {
+	ch, err := b.Broker.Channel()
+	if err != nil || b.handedOut.Swap(true) {
+		return ch, err
+	}
+	return unpublishable{ch}, nil
+}
+
+type unpublishable struct {
+	Channel
+}
+
+func (unpublishable) PublishWithContext(context.Context, string, string, bool, bool, amqp.Publishing) error {
+	return errors.New("publish failed")
+}
+
+func TestServeRunsHandlerOnceWhenReplyFails(t *testing.T) {
+	conn := newRPCBroker(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var calls atomic.Int32
+	sub, err := Serve(ctx, &failingReplies{Broker: conn}, "direct", "count", "count", SharedTransientQueue, func(context.Context, int) (int, error) {
+		calls.Add(1)
+		return 0, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	client, err := NewRPCClient(conn, JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	callCtx, callCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer callCancel()
+	_, err = Call[int, int](callCtx, client, "direct", "count", 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Call error = %v, want the caller to time out", err)
+	}
+	// give a requeued request time to come round again
+	time.Sleep(100 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler ran %v times, want 1", n)
+	}
+}
@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Subscription is a running consumer. It stops when its context is cancelled
+// or Close is called; the handler currently running is allowed to finish and
+// settle its delivery before the channel is closed.
+type Subscription struct {
+	channel    Channel
+	tag        string
+	supervisor *supervisor
+	cancel     context.CancelFunc
+	done       chan struct{}
+	once       sync.Once
+}
+
+func newSubscription(ctx context.Context, channel Channel, tag string, sup *supervisor) *Subscription {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription{
+		channel:    channel,
+		tag:        tag,
+		supervisor: sup,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			err := channel.Cancel(tag, false)
+			if err != nil && !errors.Is(err, amqp.ErrClosed) {
+				logger().Warn("could not cancel consumer", "consumer", tag, "err", err)
+				// Without a cancel the deliveries never drain; closing the
+				// channel ends them and requeues anything unacked.
+				channel.Close()
+			}
+		case <-s.done:
+		}
+	}()
+	return s
+}
+
+// finish is called by the consume loop once deliveries stop.
+func (s *Subscription) finish() {
+	s.once.Do(func() {
+		s.cancel()
+		err := s.channel.Close()
+		if err != nil && !errors.Is(err, amqp.ErrClosed) {
+			logger().Warn("could not close channel", "consumer", s.tag, "err", err)
+		}
+		close(s.done)
+	})
+}
+
+// Close stops consuming and waits for the in-flight handler to finish.
+func (s *Subscription) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// Done is closed once the subscription has fully stopped, whether through
+// Close, context cancellation, or the channel going away.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
@@ -0,0 +1,86 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	panicHeader      = "x-peril-panic"
+	panicStackHeader = "x-peril-stack"
+)
+
+// maxStackHeader keeps panic stacks well inside the broker's frame size.
+const maxStackHeader = 8 << 10
+
+// supervisor keeps a consume loop alive through handler panics. The delivery
+// being handled is dead-lettered with the panic attached and the loop is
+// started again.
+type supervisor struct {
+	channel Channel
+	queue   string
+	crashes atomic.Uint64
+}
+
+func newSupervisor(channel Channel, queue string) *supervisor {
+	return &supervisor{channel: channel, queue: queue}
+}
+
+// run calls process for every delivery until deliveries is closed.
+func (s *supervisor) run(deliveries <-chan amqp.Delivery, process func(amqp.Delivery)) {
+	for !s.loop(deliveries, process) {
+	}
+}
+
+func (s *supervisor) loop(deliveries <-chan amqp.Delivery, process func(amqp.Delivery)) (done bool) {
+	var current amqp.Delivery
+	defer func() {
+		if r := recover(); r != nil {
+			s.crashed(current, r, debug.Stack())
+			done = false
+		}
+	}()
+	for d := range deliveries {
+		current = d
+		process(d)
+	}
+	return true
+}
+
+func (s *supervisor) crashed(d amqp.Delivery, r any, stack []byte) {
+	n := s.crashes.Add(1)
+	handlerPanicsTotal.With(s.queue).Inc()
+	logger().Error("handler panicked, dead-lettering delivery", "queue", s.queue, "crashes", n, "panic", r, "stack", string(stack))
+
+	if len(stack) > maxStackHeader {
+		stack = stack[:maxStackHeader]
+	}
+	headers := copyHeaders(d)
+	headers[panicHeader] = fmt.Sprint(r)
+	headers[panicStackHeader] = string(stack)
+	headers[originalQueueHeader] = s.queue
+	err := s.channel.PublishWithContext(context.Background(), DeadLetterExchange, OriginalRoutingKey(d), false, false, republishing(d, headers))
+	if err != nil {
+		// the broker's own dead-lettering loses the panic, but not the message
+		logger().Error("could not dead-letter delivery with its panic, nacking it", "queue", s.queue, "err", err)
+		err = d.Nack(false, false)
+		if err != nil {
+			logger().Error("could not nack delivery", "queue", s.queue, "err", err)
+		}
+		return
+	}
+
+	err = d.Ack(false)
+	if err != nil {
+		logger().Error("could not ack dead-lettered delivery", "queue", s.queue, "err", err)
+	}
+}
+
+// Crashes is how many times the handler has panicked.
+func (s *Subscription) Crashes() uint64 {
+	return s.supervisor.crashes.Load()
+}
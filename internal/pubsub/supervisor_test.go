@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestSupervisorSurvivesPanics(t *testing.T) {
+	b, ch := newMemoryChannel(t)
+	declare(t, ch, DeadLetterExchange, amqp.ExchangeFanout, "dlq", "", nil)
+	if err := ch.ExchangeDeclare("peril_topic", amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	handled := make(chan string, 10)
+	conn := b.Dial()
+	defer conn.Close()
+	sub, err := SubscribeJSON(context.Background(), conn, "peril_topic", "moves", "army_moves.*", SharedTransientQueue,
+		func(move string) Acktype {
+			if move == "boom" {
+				panic("cannot move to boom")
+			}
+			handled <- move
+			return Ack
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	for _, move := range []string{"north", "boom", "east", "boom", "south"} {
+		if err := PublishJSON(context.Background(), ch, "peril_topic", "army_moves.alice", move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"north", "east", "south"} {
+		select {
+		case got := <-handled:
+			if got != want {
+				t.Errorf("handled %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+
+	if n := sub.Crashes(); n != 2 {
+		t.Errorf("counted %d crashes, want 2", n)
+	}
+	for range 2 {
+		d := get(t, ch, "dlq")
+		if string(d.Body) != `"boom"` {
+			t.Errorf("dead-lettered %s, want the message that panicked", d.Body)
+		}
+		if d.Headers[panicHeader] != "cannot move to boom" || d.Headers[originalQueueHeader] != "moves" {
+			t.Errorf("dead-lettered with panic %v from queue %v", d.Headers[panicHeader], d.Headers[originalQueueHeader])
+		}
+		if stack, _ := d.Headers[panicStackHeader].(string); !strings.Contains(stack, "TestSupervisorSurvivesPanics") {
+			t.Errorf("stack does not show the handler:\n%s", stack)
+		}
+	}
+	if n := b.QueueLength("moves"); n != 0 {
+		t.Errorf("%d messages left in moves", n)
+	}
+}
@@ -0,0 +1,50 @@
+package pubsub
+
+import (
+	"hash/fnv"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// consume hands deliveries to process on the configured number of workers
+// and returns once deliveries is closed and every worker has finished. Each
+// worker runs under sup, so a panicking handler does not stop it.
+func consume(deliveries <-chan amqp.Delivery, opts subscribeOptions, sup *supervisor, process func(amqp.Delivery)) {
+	if opts.workers == 1 {
+		sup.run(deliveries, process)
+		return
+	}
+
+	var wg sync.WaitGroup
+	if opts.orderingKey == nil {
+		for range opts.workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sup.run(deliveries, process)
+			}()
+		}
+		wg.Wait()
+		return
+	}
+
+	lanes := make([]chan amqp.Delivery, opts.workers)
+	for i := range lanes {
+		lanes[i] = make(chan amqp.Delivery)
+		wg.Add(1)
+		go func(lane <-chan amqp.Delivery) {
+			defer wg.Done()
+			sup.run(lane, process)
+		}(lanes[i])
+	}
+	for d := range deliveries {
+		h := fnv.New32a()
+		h.Write([]byte(opts.orderingKey(d)))
+		lanes[h.Sum32()%uint32(len(lanes))] <- d
+	}
+	for _, lane := range lanes {
+		close(lane)
+	}
+	wg.Wait()
+}
@@ -6,6 +6,12 @@ type PlayingState struct {
 	IsPaused bool
 }
 
+// PauseStateRequest asks the server for the current PlayingState.
+type PauseStateRequest struct{}
+
+// GameRequest asks the server for the ID and scenario of its game.
+type GameRequest struct{}
+
 type GameLog struct {
 	CurrentTime time.Time
 	Message     string
@@ -3,14 +3,38 @@ package routing
 const (
 	ArmyMovesPrefix = "army_moves"
 
-	WarRecognitionsPrefix = "war"
+	// SpawnsPrefix carries the units players spawn to the server.
+	SpawnsPrefix = "spawns"
+
+	// WarResultsPrefix carries the battles the server resolves.
+	WarResultsPrefix = "war_results"
+
+	// SpawnRejectionsPrefix carries the spawns the server refuses back to the
+	// player who made them.
+	SpawnRejectionsPrefix = "spawn_rejections"
+
+	// MoveRejectionsPrefix carries the moves the server refuses back to the
+	// player who made them.
+	MoveRejectionsPrefix = "move_rejections"
 
 	PauseKey = "pause"
 
 	GameLogSlug = "game_logs"
+
+	// PauseStateKey is where the server answers PauseStateRequests.
+	PauseStateKey = "rpc.pause_state"
+
+	// GameKey is where the server answers GameRequests.
+	GameKey = "rpc.game"
+
+	// GameOverKey carries the winner of the game to every player.
+	GameOverKey = "game_over"
 )
 
 const (
 	ExchangePerilDirect = "peril_direct"
 	ExchangePerilTopic  = "peril_topic"
+	ExchangePerilDLX    = "peril_dlx"
 )
+
+const DeadLetterQueue = "peril_dlq"
@@ -0,0 +1,188 @@
+package topology
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/config"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/pubsub"
+	"github.com/ChernakovEgor/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type Exchange struct {
+	Name    string
+	Kind    string
+	Durable bool
+}
+
+type Queue struct {
+	Name    string
+	Durable bool
+	Args    amqp.Table
+}
+
+type Binding struct {
+	Queue    string
+	Exchange string
+	Key      string
+}
+
+// Topology is the set of exchanges, queues and bindings a deployment needs.
+// Per-player transient queues are declared by the clients themselves and are
+// not part of it.
+type Topology struct {
+	Exchanges []Exchange
+	Queues    []Queue
+	Bindings  []Binding
+}
+
+// Peril returns the topology used by the Peril server and clients.
+func Peril(cfg config.Config) Topology {
+	ex, q := cfg.Exchanges, cfg.Queues
+	deadLettered := amqp.Table{"x-dead-letter-exchange": ex.DeadLetter}
+	return Topology{
+		Exchanges: []Exchange{
+			{Name: ex.Direct, Kind: amqp.ExchangeDirect, Durable: true},
+			{Name: ex.Topic, Kind: amqp.ExchangeTopic, Durable: true},
+			{Name: ex.DeadLetter, Kind: amqp.ExchangeFanout, Durable: true},
+		},
+		Queues: []Queue{
+			{Name: q.DeadLetter, Durable: true},
+			{Name: pubsub.QuarantineQueue, Durable: true},
+			{Name: q.GameLogs, Durable: q.Durable, Args: deadLettered},
+			{Name: q.Moves, Durable: q.Durable, Args: deadLettered},
+			{Name: q.Spawns, Durable: q.Durable, Args: deadLettered},
+		},
+		Bindings: []Binding{
+			{Queue: q.DeadLetter, Exchange: ex.DeadLetter, Key: ""},
+			{Queue: q.GameLogs, Exchange: ex.Topic, Key: routing.GameLogSlug + ".*"},
+			{Queue: q.Moves, Exchange: ex.Topic, Key: routing.ArmyMovesPrefix + ".*"},
+			{Queue: q.Spawns, Exchange: ex.Topic, Key: routing.SpawnsPrefix + ".*"},
+		},
+	}
+}
+
+// Apply declares everything in the topology. Declarations are idempotent, so
+// it is safe to run on every startup.
+func (t Topology) Apply(ch pubsub.Subscriber) error {
+	for _, ex := range t.Exchanges {
+		err := ch.ExchangeDeclare(ex.Name, ex.Kind, ex.Durable, false, false, false, nil)
+		if err != nil {
+			return fmt.Errorf("declaring exchange %s: %v", ex.Name, err)
+		}
+	}
+	for _, q := range t.Queues {
+		_, err := ch.QueueDeclare(q.Name, q.Durable, false, false, false, q.Args)
+		if err != nil {
+			return fmt.Errorf("declaring queue %s: %v", q.Name, err)
+		}
+	}
+	for _, bd := range t.Bindings {
+		err := ch.QueueBind(bd.Queue, bd.Key, bd.Exchange, false, nil)
+		if err != nil {
+			return fmt.Errorf("binding %s to %s with %q: %v", bd.Queue, bd.Exchange, bd.Key, err)
+		}
+	}
+	return nil
+}
+
+// Difference is one way in which a broker disagrees with a topology.
+type Difference struct {
+	Kind    string
+	Name    string
+	Problem string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s %s: %s", d.Kind, d.Name, d.Problem)
+}
+
+type passiveDeclarer interface {
+	pubsub.Channel
+	ExchangeDeclarePassive(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+}
+
+// Verify compares the broker against the topology without creating anything
+// that is missing. Exchanges and queues are checked for existence and for
+// equivalent kind, durability and arguments. AMQP has no way to list
+// bindings, so those are not checked.
+func (t Topology) Verify(b pubsub.Broker) ([]Difference, error) {
+	var diffs []Difference
+	for _, ex := range t.Exchanges {
+		problem, err := check(b,
+			func(ch passiveDeclarer) error {
+				return ch.ExchangeDeclarePassive(ex.Name, ex.Kind, ex.Durable, false, false, false, nil)
+			},
+			func(ch passiveDeclarer) error {
+				return ch.ExchangeDeclare(ex.Name, ex.Kind, ex.Durable, false, false, false, nil)
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("checking exchange %s: %v", ex.Name, err)
+		}
+		if problem != "" {
+			diffs = append(diffs, Difference{Kind: "exchange", Name: ex.Name, Problem: problem})
+		}
+	}
+	for _, q := range t.Queues {
+		problem, err := check(b,
+			func(ch passiveDeclarer) error {
+				_, err := ch.QueueDeclarePassive(q.Name, q.Durable, false, false, false, q.Args)
+				return err
+			},
+			func(ch passiveDeclarer) error {
+				_, err := ch.QueueDeclare(q.Name, q.Durable, false, false, false, q.Args)
+				return err
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("checking queue %s: %v", q.Name, err)
+		}
+		if problem != "" {
+			diffs = append(diffs, Difference{Kind: "queue", Name: q.Name, Problem: problem})
+		}
+	}
+	return diffs, nil
+}
+
+// check runs a passive declare to see whether an entity exists and, if it
+// does, a regular declare to see whether it matches. Each runs on its own
+// channel because the broker closes a channel on a failed declare.
+func check(b pubsub.Broker, exists, matches func(passiveDeclarer) error) (string, error) {
+	err := withChannel(b, exists)
+	if code, ok := amqpCode(err); ok && code == amqp.NotFound {
+		return "missing", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	err = withChannel(b, matches)
+	if code, ok := amqpCode(err); ok && code == amqp.PreconditionFailed {
+		return "declared with different settings", nil
+	}
+	return "", err
+}
+
+func withChannel(b pubsub.Broker, fn func(passiveDeclarer) error) error {
+	ch, err := b.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	pd, ok := ch.(passiveDeclarer)
+	if !ok {
+		return errors.New("channel does not support passive declares")
+	}
+	return fn(pd)
+}
+
+func amqpCode(err error) (int, bool) {
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) {
+		return amqpErr.Code, true
+	}
+	return 0, false
+}
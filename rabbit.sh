@@ -5,6 +5,34 @@ case "$1" in
         echo "Starting RabbitMQ container..."
         docker run -d --rm --name rabbitmq -p 5672:5672 -p 15672:15672 rabbitmq:3.13-management
         ;;
+    start-tls)
+        # amqps:// on 5671 using the certificates from certs.sh, with EXTERNAL
+        # auth for the user named in the client certificate
+        certs=$(cd "${2:-certs}" && pwd)
+        user=${3:-peril}
+        echo "Starting RabbitMQ container with TLS..."
+        docker run -d --rm --name rabbitmq -p 5671:5671 -p 5672:5672 -p 15672:15672 \
+            -v "$certs":/certs:ro \
+            -e RABBITMQ_ENABLED_PLUGINS="rabbitmq_management,rabbitmq_auth_mechanism_ssl" \
+            rabbitmq:3.13-management \
+            bash -c 'cat > /etc/rabbitmq/conf.d/20-tls.conf <<CONF
+listeners.ssl.default = 5671
+ssl_options.cacertfile = /certs/ca.pem
+ssl_options.certfile = /certs/server.pem
+ssl_options.keyfile = /certs/server.key
+ssl_options.verify = verify_peer
+ssl_options.fail_if_no_peer_cert = false
+auth_mechanisms.1 = PLAIN
+auth_mechanisms.2 = AMQPLAIN
+auth_mechanisms.3 = EXTERNAL
+ssl_cert_login_from = common_name
+CONF
+exec docker-entrypoint.sh rabbitmq-server'
+        until docker exec rabbitmq rabbitmqctl await_startup >/dev/null 2>&1; do sleep 1; done
+        docker exec rabbitmq rabbitmqctl add_user "$user" "$(head -c 16 /dev/urandom | base64)"
+        docker exec rabbitmq rabbitmqctl clear_password "$user"
+        docker exec rabbitmq rabbitmqctl set_permissions "$user" ".*" ".*" ".*"
+        ;;
     stop)
         echo "Stopping RabbitMQ container..."
         docker stop rabbitmq
@@ -14,6 +42,6 @@ case "$1" in
         docker logs -f rabbitmq
         ;;
     *)
-        echo "Usage: $0 {start|stop|logs}"
+        echo "Usage: $0 {start|start-tls [certs-dir] [user]|stop|logs}"
         exit 1
 esac